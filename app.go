@@ -5,11 +5,15 @@ import (
 	"crypto/rand"
 	"fmt"
 	"html"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/dfirebaugh/mdsrs/config"
+	"github.com/dfirebaugh/mdsrs/diff"
 	"github.com/dfirebaugh/mdsrs/md"
 	"github.com/dfirebaugh/mdsrs/models"
-	"github.com/dfirebaugh/mdsrs/srs"
+	"github.com/dfirebaugh/mdsrs/profile"
 	"github.com/dfirebaugh/mdsrs/store"
 
 	"github.com/sirupsen/logrus"
@@ -17,47 +21,89 @@ import (
 
 type App struct {
 	*config.Config
+	store *store.Store
 	decks map[string]*models.Deck
 	srs   models.SRS
 	ctx   context.Context
+
+	profiles *profile.Manager
+	profile  string
+	// htmlPolicy sanitizes the HTML of cards outside the trusted decks.
+	htmlPolicy *md.Policy
+	// mathMode is how the active profile renders math.
+	mathMode md.MathMode
+	// renderCache persists the active profile's rendered HTML, or is nil.
+	renderCache *store.RenderCache
+	// mu is held for writing while the active profile's store is swapped
+	// out and while decks, or the cards in them, are changed, and for
+	// reading by everything else that uses the store or the decks.
+	mu sync.RWMutex
 }
 
 func NewApp() *App {
 	a := &App{
-		Config: config.NewConfig(),
-		decks:  make(map[string]*models.Deck),
+		Config:     config.NewConfig(),
+		decks:      make(map[string]*models.Deck),
+		profiles:   profile.NewManager(profile.DefaultRoot),
+		htmlPolicy: md.DefaultPolicy(),
 	}
 
-	if c, err := config.LoadConfigFromFile(".mdsrs/config.json"); err != nil {
-		logrus.Warnf("Failed to load config file: %v, using default config", err)
-	} else {
-		a.Config = c
+	if err := a.profiles.Init(); err != nil {
+		logrus.Fatalf("Failed to initialize profiles: %v", err)
+	}
+
+	name := a.profiles.Active()
+	if !a.profiles.Exists(name) {
+		if err := a.profiles.Create(name); err != nil {
+			logrus.Fatalf("Failed to create profile %s: %v", name, err)
+		}
 	}
-	store.SetDBPath(a.Config.DBFile)
 
-	if err := store.InitDB(); err != nil {
-		logrus.Fatalf("Failed to initialize database: %v", err)
+	if err := a.openProfile(name); err != nil {
+		logrus.Fatalf("Failed to open profile %s: %v", name, err)
 	}
 
-	a.srs = srs.NewSRS("", store.GetDB())
+	return a
+}
 
-	decks, err := store.LoadAllDecks()
+// reloadDecks refreshes the in-memory decks after changes made directly in
+// the store. The map is updated in place because other services share it.
+func (a *App) reloadDecks() error {
+	decks, err := a.store.LoadAllDecks()
 	if err != nil {
-		logrus.Fatalf("Failed to load decks: %v", err)
+		return fmt.Errorf("failed to reload decks: %w", err)
 	}
 
+	for name := range a.decks {
+		delete(a.decks, name)
+	}
 	for _, deck := range decks {
 		a.decks[deck.Name] = deck
 	}
-
-	return a
+	return nil
 }
 
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
+func (a *App) shutdown(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.Close(); err != nil {
+		logrus.Errorf("Error closing database: %v", err)
+	}
+	md.DefaultCache.SetStore(nil)
+	if err := a.renderCache.Close(); err != nil {
+		logrus.Errorf("Error closing render cache: %v", err)
+	}
+}
+
 func (a *App) GetDecks() map[string]*models.Deck {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	for _, deck := range a.decks {
 		if deck != nil && deck.Cards == nil {
 			deck.Cards = []models.Flashcard{}
@@ -67,7 +113,10 @@ func (a *App) GetDecks() map[string]*models.Deck {
 }
 
 func (a *App) NewDeck(name string) *models.Deck {
-	deck := store.NewDeck(name)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.store.NewDeck(name)
 	if deck == nil {
 		logrus.Errorf("Failed to create deck: %s", name)
 		return nil
@@ -77,6 +126,9 @@ func (a *App) NewDeck(name string) *models.Deck {
 }
 
 func (a *App) AddOrUpdateCard(deckID string, cardID string, title string, content string) models.Flashcard {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if cardID == "" {
 		cardID = store.GenerateID()
 	}
@@ -101,9 +153,9 @@ func (a *App) AddOrUpdateCard(deckID string, cardID string, title string, conten
 	}
 	var err error
 	if found {
-		err = store.AddOrUpdateCard(d, card)
+		err = a.store.AddOrUpdateCard(d, card)
 	} else {
-		err = store.AddCard(d, card)
+		err = a.store.AddCard(d, card)
 	}
 	if err != nil {
 		logrus.Errorf("Failed to add/update card: %v", err)
@@ -114,14 +166,20 @@ func (a *App) AddOrUpdateCard(deckID string, cardID string, title string, conten
 }
 
 func (a *App) DeleteCardFromDeck(deckID string, cardID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	deck := a.decks[deckID]
 	if deck == nil {
 		return fmt.Errorf("deck not found: %s", deckID)
 	}
-	return store.DeleteCard(deck, cardID)
+	return a.store.DeleteCard(deck, cardID)
 }
 
 func (a *App) DeleteDeck(deckID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	if deckID == "" {
 		return fmt.Errorf("deck ID cannot be empty")
 	}
@@ -130,7 +188,7 @@ func (a *App) DeleteDeck(deckID string) error {
 		return fmt.Errorf("deck not found: %s", deckID)
 	}
 
-	if err := store.DeleteDeck(deckID); err != nil {
+	if err := a.store.DeleteDeck(deckID); err != nil {
 		return fmt.Errorf("failed to delete deck from store: %w", err)
 	}
 
@@ -140,7 +198,63 @@ func (a *App) DeleteDeck(deckID string) error {
 	return nil
 }
 
+func (a *App) ListTrash() ([]models.TrashItem, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.ListTrash()
+}
+
+// RestoreFromTrash restores a trashed card or deck, as listed by ListTrash.
+func (a *App) RestoreFromTrash(kind string, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	switch kind {
+	case store.TrashKindCard:
+		err = a.store.RestoreCard(id)
+	case store.TrashKindDeck:
+		err = a.store.RestoreDeck(id)
+	default:
+		return fmt.Errorf("unknown trash item kind: %s", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.reloadDecks()
+}
+
+// PurgeTrash permanently deletes items that have been in the trash longer
+// than the configured retention period, returning the number of cards deleted.
+func (a *App) PurgeTrash() (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.purgeTrash()
+}
+
+func (a *App) purgeTrash() (int, error) {
+	days := a.Config.TrashRetentionDays
+	if days < 0 {
+		return 0, nil
+	}
+	return a.store.PurgeTrash(time.Now().AddDate(0, 0, -days).Unix())
+}
+
+// EmptyTrash permanently deletes everything in the trash.
+func (a *App) EmptyTrash() (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.PurgeTrash(time.Now().Unix())
+}
+
 func (a *App) GetCardContent(deckID string, cardID string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if deckID == "" {
 		println("deckID is empty, cannot get card content")
 		return ""
@@ -159,6 +273,9 @@ func (a *App) GetCardContent(deckID string, cardID string) string {
 }
 
 func (a *App) UpdateSRSData(deckID string, cardID string, reviewConfidence models.ReviewConfidence) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	logrus.Infof("App.UpdateSRSData called with deckID: %s, cardID: %s, reviewConfidence: %d", deckID, cardID, reviewConfidence)
 
 	if a.srs == nil {
@@ -171,6 +288,9 @@ func (a *App) UpdateSRSData(deckID string, cardID string, reviewConfidence model
 }
 
 func (a *App) GetCardSRSData(cardID string) models.CardData {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.srs == nil {
 		logrus.Error("SRS is nil in App")
 		return models.CardData{}
@@ -179,7 +299,35 @@ func (a *App) GetCardSRSData(cardID string) models.CardData {
 	return a.srs.GetCardData(cardID)
 }
 
+// ResetCard returns a card to new, clearing its review log unless keepLog
+// is set.
+func (a *App) ResetCard(cardID string, keepLog bool) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.srs.ResetCard(cardID, keepLog)
+}
+
+// SetDueDate makes a card due at a random time between from and to (Unix
+// seconds). Pass the same time twice for a fixed date.
+func (a *App) SetDueDate(cardID string, from int64, to int64) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.srs.SetDueDate(cardID, from, to)
+}
+
+func (a *App) GetReviewLog(cardID string) ([]models.Review, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.srs.GetReviewLog(cardID)
+}
+
 func (a *App) GetFutureReviewCards() []models.Flashcard {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.srs == nil {
 		logrus.Error("SRS is nil in App")
 		return []models.Flashcard{}
@@ -197,6 +345,10 @@ func (a *App) GetReviewCardsForDeck(deckName string) []models.Flashcard {
 		logrus.Error("GetReviewCards called on nil App reference")
 		return []models.Flashcard{}
 	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.decks == nil {
 		logrus.Error("no decks available")
 		return []models.Flashcard{}
@@ -227,12 +379,134 @@ func (a *App) GetReviewCardsForDeck(deckName string) []models.Flashcard {
 	return a.srs.GetReviewCardsForDecks(deckNames, numCards)
 }
 
+// ToHTML renders markdown. Markdown that fails to render comes back as an
+// error block rather than failing the call, so the UI can show it in place.
+// The HTML is sanitized, since the markdown isn't known to come from a
+// trusted deck.
 func (a *App) ToHTML(content string) string {
-	println(content)
-	return string(md.ToHTML([]byte(content)))
+	r := a.renderer("")
+	return r.html(md.ToHTML([]byte(content), r.opts))
+}
+
+// renderer returns the htmlRenderer for a deck's cards, which renders math
+// the way the active profile does and leaves the HTML of a trusted deck
+// unsanitized.
+func (a *App) renderer(deckID string) htmlRenderer {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	r := htmlRenderer{policy: a.htmlPolicy, opts: md.Options{Math: a.mathMode}}
+	if deckID != "" && slices.Contains(a.Config.TrustedDecks, deckID) {
+		r.policy = nil
+	}
+	return r
+}
+
+// htmlPolicy returns the default sanitization policy extended with the
+// elements and attributes a config allows.
+func htmlPolicy(cfg *config.Config) *md.Policy {
+	policy := md.DefaultPolicy()
+	for element, attrs := range cfg.AllowedHTML {
+		if element == "*" {
+			element = ""
+		}
+		policy.AllowAttributes(element, attrs...)
+	}
+	return policy
+}
+
+// htmlRenderer renders markdown with opts and prepares it for the UI,
+// sanitizing it with policy. A nil policy leaves the HTML as it is.
+type htmlRenderer struct {
+	policy *md.Policy
+	opts   md.Options
+}
+
+// html returns rendered HTML, or an error block if rendering failed.
+func (r htmlRenderer) html(out []byte, err error) string {
+	if err != nil {
+		logrus.Errorf("Failed to render markdown: %v", err)
+		return string(md.ErrorHTML(err))
+	}
+	if r.policy != nil {
+		out = r.policy.Sanitize(out)
+	}
+	return string(out)
+}
+
+// RenderCard splits card content into its front, back and extra sections and
+// renders each to HTML. Content with cloze deletions renders as the card for
+// its first cloze. Like ToHTML, the HTML is sanitized.
+func (a *App) RenderCard(content string) (models.RenderedCard, error) {
+	cloze := 0
+	if numbers := md.ClozeNumbers([]byte(content)); len(numbers) > 0 {
+		cloze = numbers[0]
+	}
+	return renderCard(content, cloze, a.renderer(""))
+}
+
+// RenderCardByID renders a card from a deck like RenderCard, hiding the
+// cloze the card asks for and noting whether the deck autoplays sounds. The
+// HTML is sanitized unless the deck is trusted.
+func (a *App) RenderCardByID(deckID string, cardID string) (models.RenderedCard, error) {
+	a.mu.RLock()
+	deck, ok := a.decks[deckID]
+	if !ok || deck == nil {
+		a.mu.RUnlock()
+		return models.RenderedCard{}, fmt.Errorf("deck not found: %s", deckID)
+	}
+
+	var card *models.Flashcard
+	for i := range deck.Cards {
+		if deck.Cards[i].ID == cardID {
+			c := deck.Cards[i]
+			card = &c
+			break
+		}
+	}
+	autoplay := deck.AutoplayAudio
+	a.mu.RUnlock()
+
+	if card == nil {
+		return models.RenderedCard{}, fmt.Errorf("card not found: %s", cardID)
+	}
+	rendered, err := renderCard(card.Content, card.Cloze, a.renderer(deckID))
+	if err != nil {
+		return rendered, err
+	}
+	rendered.Autoplay = autoplay
+	return rendered, nil
+}
+
+// renderCard renders parsed card content. For a cloze card the front hides
+// cloze number cloze and the back reveals it above the card's own back.
+func renderCard(content string, cloze int, r htmlRenderer) (models.RenderedCard, error) {
+	card, err := md.ParseCard([]byte(content))
+	if err != nil {
+		return models.RenderedCard{}, err
+	}
+
+	rendered := models.RenderedCard{
+		Front: r.html(md.ToHTML([]byte(card.Front), r.opts)),
+		Back:  r.html(md.ToHTML([]byte(card.Back), r.opts)),
+		Extra: make([]string, len(card.Extra)),
+		Title: card.Title,
+		Tags:  card.Tags,
+	}
+	if cloze > 0 {
+		rendered.Front = r.html(md.ClozeToHTML([]byte(card.Front), cloze, false, r.opts))
+		rendered.Back = r.html(md.ClozeToHTML([]byte(card.Front), cloze, true, r.opts)) + rendered.Back
+	}
+	for i, extra := range card.Extra {
+		rendered.Extra[i] = r.html(md.ToHTML([]byte(extra), r.opts))
+	}
+	return rendered, nil
 }
 
 func (a *App) GetCardsFromDeck(deckName string) []models.Flashcard {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	deck, ok := a.decks[deckName]
 	if !ok || deck == nil {
 		println(ok, deck.Name)
@@ -247,6 +521,10 @@ func (a *App) GetCards(deckNames []string, num int) []models.Flashcard {
 		logrus.Error("GetCards called on nil App reference")
 		return []models.Flashcard{}
 	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if a.decks == nil {
 		logrus.Error("no decks available")
 		return []models.Flashcard{}
@@ -268,6 +546,207 @@ func (a *App) GetCards(deckNames []string, num int) []models.Flashcard {
 	return a.srs.GetReviewCardsForDecks(deckNames, num)
 }
 
+// SearchCards returns the cards matching a search expression such as
+// "deck:Go tag:concurrency is:due -is:suspended", sorted by deck, title,
+// created, updated, due, reviews or ease.
+func (a *App) SearchCards(query string, sortBy string, descending bool) ([]models.Flashcard, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.SearchCards(query, store.SearchOptions{
+		SortBy:     sortBy,
+		Descending: descending,
+	})
+}
+
+// GetCustomStudyCards returns up to num cards matching a search expression
+// for a study session outside the regular review schedule.
+func (a *App) GetCustomStudyCards(query string, num int) ([]models.Flashcard, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.srs == nil {
+		return nil, fmt.Errorf("SRS is not initialized")
+	}
+	if num <= 0 {
+		num = a.Config.NumberOfCardsInReview
+	}
+
+	return a.srs.GetCustomStudyCards(query, num)
+}
+
+func (a *App) ListTags() ([]models.Tag, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.ListTags()
+}
+
+func (a *App) CardsByTag(tag string) ([]models.Flashcard, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.CardsByTag(tag)
+}
+
+// RenameTag renames a tag and its children across the collection and
+// returns the number of cards changed.
+func (a *App) RenameTag(oldTag string, newTag string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, err := a.store.RenameTag(oldTag, newTag)
+	if err != nil {
+		return 0, err
+	}
+	return n, a.reloadDecks()
+}
+
+// ListCardRevisions returns the previous versions of a card, newest first.
+func (a *App) ListCardRevisions(cardID string) ([]models.CardRevision, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.ListCardRevisions(cardID)
+}
+
+// DiffCardRevisions compares two revisions of a card line by line. Revision
+// ID 0 is the card's current version.
+func (a *App) DiffCardRevisions(cardID string, fromRevisionID int64, toRevisionID int64) ([]diff.Line, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	from, err := a.store.GetCardRevision(cardID, fromRevisionID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := a.store.GetCardRevision(cardID, toRevisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Lines(from.Title+"\n\n"+from.Content, to.Title+"\n\n"+to.Content), nil
+}
+
+// RestoreCardRevision replaces a card's content with an old revision.
+func (a *App) RestoreCardRevision(deckID string, cardID string, revisionID int64) (models.Flashcard, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return models.Flashcard{}, fmt.Errorf("deck not found: %s", deckID)
+	}
+	return a.store.RestoreCardRevision(deck, cardID, revisionID)
+}
+
+func (a *App) SetCardSuspended(deckID string, cardID string, suspended bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return fmt.Errorf("deck not found: %s", deckID)
+	}
+	return a.store.SetCardSuspended(deck, cardID, suspended)
+}
+
+// SetCardFlag flags a card with one of models.FlagNames, by index. Zero
+// clears the flag.
+func (a *App) SetCardFlag(cardID string, flag models.Flag) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.SetCardFlag(cardID, flag); err != nil {
+		return err
+	}
+	a.updateCachedCard(cardID, func(card *models.Flashcard) { card.Flag = flag })
+	return nil
+}
+
+func (a *App) SetCardMarked(cardID string, marked bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.SetCardMarked(cardID, marked); err != nil {
+		return err
+	}
+	a.updateCachedCard(cardID, func(card *models.Flashcard) { card.Marked = marked })
+	return nil
+}
+
+// updateCachedCard applies update to the in-memory copy of a card. The
+// caller must hold a.mu for writing.
+func (a *App) updateCachedCard(cardID string, update func(card *models.Flashcard)) {
+	for _, deck := range a.decks {
+		for i := range deck.Cards {
+			if deck.Cards[i].ID == cardID {
+				update(&deck.Cards[i])
+				return
+			}
+		}
+	}
+}
+
+// FindDuplicates returns pairs of cards with the same or nearly the same
+// front, in one deck or, when deckID is empty, across the collection.
+func (a *App) FindDuplicates(deckID string, threshold float64) ([]models.DuplicatePair, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.FindDuplicates(deckID, threshold)
+}
+
+// MergeCards keeps one of two duplicate cards, with the better SRS history of
+// the two, and moves the other to the trash.
+func (a *App) MergeCards(keepID string, duplicateID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.store.MergeCards(keepID, duplicateID); err != nil {
+		return err
+	}
+	return a.reloadDecks()
+}
+
+// MoveCard moves a card to index in its deck's card list.
+func (a *App) MoveCard(deckID string, cardID string, index int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return fmt.Errorf("deck not found: %s", deckID)
+	}
+	return a.store.MoveCard(deck, cardID, index)
+}
+
+// SetNewCardOrder sets whether a deck's new cards are studied in list order
+// ("position") or shuffled ("random").
+func (a *App) SetNewCardOrder(deckID string, order string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return fmt.Errorf("deck not found: %s", deckID)
+	}
+	return a.store.SetNewCardOrder(deck, order)
+}
+
+// SetAutoplayAudio sets whether a deck's sounds play automatically when a
+// card is shown and when its back is revealed.
+func (a *App) SetAutoplayAudio(deckID string, autoplay bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return fmt.Errorf("deck not found: %s", deckID)
+	}
+	return a.store.SetAutoplayAudio(deck, autoplay)
+}
+
 func (a *App) EscapeHtml(text string) string {
 	if text == "" {
 		return ""
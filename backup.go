@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/store"
+	"github.com/sirupsen/logrus"
+)
+
+// ListBackups returns the active profile's backups, newest first.
+func (a *App) ListBackups() ([]models.Backup, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return store.ListBackups(store.BackupDir(a.Config.DBFile))
+}
+
+// BackupNow takes a backup outside the regular schedule and returns its name.
+func (a *App) BackupNow() (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	keep := a.Config.BackupCount
+	if keep <= 0 {
+		return "", fmt.Errorf("backups are disabled")
+	}
+
+	path, err := a.store.Backup(store.BackupDir(a.Config.DBFile), keep)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(path), nil
+}
+
+// RestoreBackup replaces the active collection with a backup. The current
+// state is backed up first when the database is closed. If the backup can't
+// be restored or opened, the current collection is opened again.
+func (a *App) RestoreBackup(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dbPath := a.Config.DBFile
+	staged, err := store.StageBackup(store.BackupDir(dbPath), name, dbPath)
+	if err != nil {
+		return err
+	}
+	// A restore moves the staged copy into place; after a failed one it
+	// isn't needed.
+	defer os.Remove(staged)
+
+	if err := a.store.Close(); err != nil {
+		return a.reopenProfile(fmt.Errorf("failed to close database: %w", err))
+	}
+
+	if err := store.RestoreBackup(staged, dbPath); err != nil {
+		return a.reopenProfile(err)
+	}
+
+	if err := a.openProfile(a.profile); err != nil {
+		// openProfile may have left the restored database open.
+		if closeErr := a.store.Close(); closeErr != nil {
+			logrus.Errorf("Error closing database: %v", closeErr)
+		}
+		if undoErr := store.UndoRestore(dbPath); undoErr != nil {
+			logrus.Errorf("Failed to undo restore: %v", undoErr)
+		}
+		return a.reopenProfile(fmt.Errorf("failed to open restored backup: %w", err))
+	}
+
+	if err := store.FinishRestore(dbPath); err != nil {
+		logrus.Errorf("Failed to finish restore: %v", err)
+	}
+	return nil
+}
+
+// reopenProfile opens the active profile again after its database was
+// closed by a failed restore, and returns err.
+func (a *App) reopenProfile(err error) error {
+	if openErr := a.openProfile(a.profile); openErr != nil {
+		return fmt.Errorf("%w (and failed to reopen the collection: %v)", err, openErr)
+	}
+	return err
+}
+
+// CheckDatabase reports corruption and inconsistent rows in the active
+// collection, and fixes the inconsistencies if repair is set.
+func (a *App) CheckDatabase(repair bool) (*models.IntegrityReport, error) {
+	if !repair {
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+
+		return a.store.CheckDatabase(false)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	report, err := a.store.CheckDatabase(true)
+	if err != nil {
+		return report, err
+	}
+	return report, a.reloadDecks()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// bulk applies op to the selected cards and reloads the decks if any
+// changed.
+func (a *App) bulk(sel models.CardSelection, op func(cardIDs []string) (int, error)) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ids, err := a.store.SelectCards(sel)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := op(ids)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, a.reloadDecks()
+}
+
+// MoveCards moves the selected cards to the end of a deck, which is created
+// if it doesn't exist.
+func (a *App) MoveCards(sel models.CardSelection, deckID string) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.store.MoveCards(ids, deckID)
+	})
+}
+
+// AddTagsToCards tags the selected cards without editing their content.
+func (a *App) AddTagsToCards(sel models.CardSelection, tags []string) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.store.AddTags(ids, tags)
+	})
+}
+
+// RemoveTagsFromCards untags the selected cards, removing matching
+// #hashtags from their content.
+func (a *App) RemoveTagsFromCards(sel models.CardSelection, tags []string) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.store.RemoveTags(ids, tags)
+	})
+}
+
+func (a *App) SuspendCards(sel models.CardSelection, suspended bool) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.store.SetCardsSuspended(ids, suspended)
+	})
+}
+
+// ResetCards returns the selected cards to new, clearing their review log
+// unless keepLog is set.
+func (a *App) ResetCards(sel models.CardSelection, keepLog bool) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.srs.ResetCards(ids, keepLog)
+	})
+}
+
+// RescheduleCards makes the selected cards due at random times between from
+// and to (Unix seconds).
+func (a *App) RescheduleCards(sel models.CardSelection, from int64, to int64) (int, error) {
+	return a.bulk(sel, func(ids []string) (int, error) {
+		return a.srs.SetDueDates(ids, from, to)
+	})
+}
+
+// DeleteCards moves the selected cards to the trash.
+func (a *App) DeleteCards(sel models.CardSelection) (int, error) {
+	return a.bulk(sel, a.store.DeleteCards)
+}
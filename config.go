@@ -7,6 +7,8 @@ import (
 
 type ConfigService struct {
 	Config *config.Config
+	// app supplies the active profile's config file.
+	app *App
 }
 
 type ConfigResult struct {
@@ -20,13 +22,13 @@ func (c *ConfigService) Save(configJSON string) {
 	}
 	println(configJSON)
 
-	if err := c.Config.SaveConfig(".mdsrs/config.json"); err != nil {
+	if err := c.Config.SaveConfig(c.app.configPath()); err != nil {
 		logrus.Error(err)
 	}
 }
 
 func (c *ConfigService) Load() ConfigResult {
-	cfg, err := config.LoadConfigFromFile(".mdsrs/config.json")
+	cfg, err := config.LoadConfigFromFile(c.app.configPath())
 	if err != nil {
 		logrus.Error("Error loading config:", err)
 		return ConfigResult{
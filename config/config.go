@@ -12,6 +12,28 @@ type Config struct {
 	NumberOfCardsInReview int    `json:"numberOfCardsInReview"`
 	VimMode               bool   `json:"vimMode"`
 	LineNumbers           bool   `json:"lineNumbers"`
+	// TrashRetentionDays is how long deleted cards and decks stay in the
+	// trash. 0 purges them whenever the trash is purged, such as on startup,
+	// and a negative number keeps them until the trash is emptied.
+	TrashRetentionDays int `json:"trashRetentionDays"`
+	// BackupCount is the number of backups kept. 0 turns backups off.
+	BackupCount int `json:"backupCount"`
+	// BackupIntervalMinutes is the time between backups while the app is
+	// open. 0 only backs up on startup and shutdown.
+	BackupIntervalMinutes int `json:"backupIntervalMinutes"`
+	// PersistRenderCache keeps rendered card HTML between runs, in a
+	// database next to the collection.
+	PersistRenderCache bool `json:"persistRenderCache"`
+	// TrustedDecks are rendered with any HTML their cards contain. Cards in
+	// other decks are sanitized.
+	TrustedDecks []string `json:"trustedDecks"`
+	// AllowedHTML adds elements, and the attributes allowed on them, to what
+	// sanitized cards may contain. The element "*" lists attributes allowed
+	// on every element.
+	AllowedHTML map[string][]string `json:"allowedHtml"`
+	// MathRendering is "mathjax" to typeset math in the webview, or
+	// "mathml" to convert it to MathML when cards are rendered.
+	MathRendering string `json:"mathRendering"`
 }
 
 func LoadConfigFromFile(filePath string) (*Config, error) {
@@ -30,7 +52,8 @@ func LoadConfigFromFile(filePath string) (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
+	// Settings missing from the file keep their defaults.
+	config := *NewConfig()
 	err = json.Unmarshal(data, &config)
 	if err != nil {
 		return nil, err
@@ -52,6 +75,9 @@ func NewConfig() *Config {
 		NumberOfCardsInReview: 20,
 		DBFile:                ".mdsrs/mdsrs.db",
 		VimMode:               false,
+		TrashRetentionDays:    30,
+		BackupCount:           10,
+		BackupIntervalMinutes: 60,
 	}
 }
 
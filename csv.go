@@ -1,18 +1,20 @@
 package main
 
 import (
-	"github.com/dfirebaugh/mdsrs/models"
 	"github.com/dfirebaugh/mdsrs/store"
 	"github.com/sirupsen/logrus"
 )
 
 
 type CSVService struct {
-	decks map[string]*models.Deck
+	app *App
 }
 
 func (a *CSVService) ExportDeck(deckName string) string {
-	deck, ok := a.decks[deckName]
+	a.app.mu.RLock()
+	defer a.app.mu.RUnlock()
+
+	deck, ok := a.app.decks[deckName]
 	if !ok {
 		logrus.Errorf("deck not found: %s", deckName)
 		return ""
@@ -28,11 +30,28 @@ func (a *CSVService) ExportDeck(deckName string) string {
 }
 
 func (a *CSVService) ImportDeck(deckName string, csvData string) error {
-	deck, err := store.ImportCSVDeck(csvData, deckName)
+	a.app.mu.Lock()
+	defer a.app.mu.Unlock()
+
+	deck, err := a.app.store.ImportCSVDeck(csvData, deckName)
 	if err != nil {
 		return err
 	}
 
-	a.decks[deck.Name] = deck
+	a.app.decks[deck.Name] = deck
 	return nil
 }
+
+// ExportNotes exports the fields of a deck's notes of one note type.
+func (a *CSVService) ExportNotes(deckName string, noteTypeID string) string {
+	a.app.mu.RLock()
+	defer a.app.mu.RUnlock()
+
+	csvData, err := a.app.store.ExportNotesToCSV(deckName, noteTypeID)
+	if err != nil {
+		logrus.Errorf("failed to export notes to CSV: %v", err)
+		return ""
+	}
+
+	return csvData
+}
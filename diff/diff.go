@@ -0,0 +1,91 @@
+// Package diff computes line-based differences between two texts.
+package diff
+
+import (
+	"strings"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the edit script turning a into b, one entry per line, based
+// on the longest common subsequence of their lines.
+func Lines(a, b string) []Line {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]Line, 0, max(len(x), len(y)))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Insert, y[j]})
+	}
+
+	return lines
+}
+
+// Unified formats an edit script with "+", "-" and " " line prefixes.
+func Unified(lines []Line) string {
+	var b strings.Builder
+	for _, line := range lines {
+		switch line.Op {
+		case Insert:
+			b.WriteString("+")
+		case Delete:
+			b.WriteString("-")
+		default:
+			b.WriteString(" ")
+		}
+		b.WriteString(line.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
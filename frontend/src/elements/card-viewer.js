@@ -1,4 +1,3 @@
-import { marked } from "marked";
 import "../styles/markdown.css";
 
 export default function CardViewer({ SRS, ConfigService }) {
@@ -18,14 +17,6 @@ export default function CardViewer({ SRS, ConfigService }) {
 				easyCount: 0,
 			};
 			this.handleKeyPress = this.handleKeyPress.bind(this);
-
-			marked.setOptions({
-				gfm: true,
-				breaks: true,
-				headerIds: true,
-				mangle: false,
-				sanitize: false,
-			});
 		}
 
 		async escapeHtmlAttribute(text) {
@@ -55,10 +46,13 @@ export default function CardViewer({ SRS, ConfigService }) {
 			document.addEventListener("keydown", this.handleKeyPress);
 		}
 
-		async getCardContent(content) {
-			const html = await SRS.ToHTML(content);
-			const htmlContent = marked(html);
-			return [htmlContent, null];
+		async getCardContent(deckID, cardID) {
+			try {
+				const card = await SRS.RenderCardByID(deckID, cardID);
+				return [card, null];
+			} catch (err) {
+				return [null, err];
+			}
 		}
 
 		async loadConfig() {
@@ -320,7 +314,8 @@ export default function CardViewer({ SRS, ConfigService }) {
 			}
 
 			const [content, contentError] = await this.getCardContent(
-				freshCard.content || "",
+				currentCard.deckId,
+				currentCard.id,
 			);
 			if (contentError) {
 				console.error("Error getting card content:", contentError);
@@ -328,9 +323,12 @@ export default function CardViewer({ SRS, ConfigService }) {
 				return;
 			}
 
-			const parts = content.split("<card-back>");
-			const front = parts[0]?.trim() || "";
-			const back = parts[1]?.trim() || "";
+			const front = content.front;
+			const back =
+				content.back +
+				(content.extra || [])
+					.map((extra) => `<div class="card-extra">${extra}</div>`)
+					.join("");
 
 			if (this.isEditing) {
 				this.innerHTML = `<card-editor></card-editor>`;
@@ -406,6 +404,23 @@ export default function CardViewer({ SRS, ConfigService }) {
 					nav.updateDisplay();
 				}
 			}
+
+			if (content.autoplay) {
+				this.playAudio();
+			}
+		}
+
+		// playAudio plays the sounds on the side of the card being shown, one
+		// after another.
+		playAudio() {
+			const side = this.isFlipped ? ".card-back" : ".card-front";
+			const sounds = [...this.querySelectorAll(`${side} audio`)];
+			sounds.forEach((sound, i) => {
+				if (sounds[i + 1]) {
+					sound.addEventListener("ended", () => sounds[i + 1].play().catch(console.error));
+				}
+			});
+			sounds[0]?.play().catch(console.error);
 		}
 
 		async getFreshCard(deckID, cardID) {
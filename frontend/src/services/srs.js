@@ -189,6 +189,25 @@ export default class SRS {
 		return App.ToHTML(arg1);
 	}
 
+	/**
+	 * Split card content into its sections and render each to HTML.
+	 * @param {string} content - The card's markdown content.
+	 * @returns {Promise<Object>} The rendered front, back and extra sections.
+	 */
+	static RenderCard(arg1) {
+		return App.RenderCard(arg1);
+	}
+
+	/**
+	 * Render a card from a deck, hiding the cloze the card asks for.
+	 * @param {string} deckID - The deck identifier.
+	 * @param {string} cardID - The card identifier.
+	 * @returns {Promise<Object>} The rendered front, back and extra sections.
+	 */
+	static RenderCardByID(arg1, arg2) {
+		return App.RenderCardByID(arg1, arg2);
+	}
+
 	/**
 	 * Update the configuration from a JSON string.
 	 * @param {string} configJSON - The configuration as a JSON string.
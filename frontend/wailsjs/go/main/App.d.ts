@@ -1,18 +1,59 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
+import {diff} from '../models';
 import {config} from '../models';
 
+export function AddImage(arg1:string,arg2:Array<number>):Promise<string>;
+
+export function AddImageFile(arg1:string):Promise<string>;
+
+export function AddNoteType(arg1:models.NoteType):Promise<models.NoteType>;
+
 export function AddOrUpdateCard(arg1:string,arg2:string,arg3:string,arg4:string):Promise<models.Flashcard>;
 
+export function AddOrUpdateNote(arg1:string,arg2:string,arg3:string,arg4:Record<string, string>):Promise<models.Note>;
+
+export function AddSound(arg1:string,arg2:Array<number>):Promise<string>;
+
+export function AddSoundFile(arg1:string):Promise<string>;
+
+export function AddTagsToCards(arg1:models.CardSelection,arg2:Array<string>):Promise<number>;
+
+export function BackupNow():Promise<string>;
+
+export function CardsByTag(arg1:string):Promise<Array<models.Flashcard>>;
+
+export function CheckDatabase(arg1:boolean):Promise<models.IntegrityReport>;
+
+export function CollectMedia():Promise<number>;
+
+export function CreateProfile(arg1:string):Promise<void>;
+
+export function CurrentProfile():Promise<string>;
+
 export function DeleteCardFromDeck(arg1:string,arg2:string):Promise<void>;
 
+export function DeleteCards(arg1:models.CardSelection):Promise<number>;
+
 export function DeleteDeck(arg1:string):Promise<void>;
 
+export function DeleteNote(arg1:string):Promise<number>;
+
+export function DeleteNoteType(arg1:string):Promise<void>;
+
+export function DeleteProfile(arg1:string):Promise<void>;
+
+export function DiffCardRevisions(arg1:string,arg2:number,arg3:number):Promise<Array<diff.Line>>;
+
+export function EmptyTrash():Promise<number>;
+
 export function EscapeHtml(arg1:string):Promise<string>;
 
 export function EscapeHtmlAttribute(arg1:string):Promise<string>;
 
+export function FindDuplicates(arg1:string,arg2:number):Promise<Array<models.DuplicatePair>>;
+
 export function GenerateID():Promise<string>;
 
 export function GetCardContent(arg1:string,arg2:string):Promise<string>;
@@ -23,20 +64,84 @@ export function GetCards(arg1:Array<string>,arg2:number):Promise<Array<models.Fl
 
 export function GetCardsFromDeck(arg1:string):Promise<Array<models.Flashcard>>;
 
+export function GetCustomStudyCards(arg1:string,arg2:number):Promise<Array<models.Flashcard>>;
+
 export function GetDecks():Promise<Record<string, models.Deck>>;
 
 export function GetFutureReviewCards():Promise<Array<models.Flashcard>>;
 
+export function GetNote(arg1:string):Promise<models.Note>;
+
 export function GetReviewCards():Promise<Array<models.Flashcard>>;
 
 export function GetReviewCardsForDeck(arg1:string):Promise<Array<models.Flashcard>>;
 
+export function GetReviewLog(arg1:string):Promise<Array<models.Review>>;
+
+export function ListBackups():Promise<Array<models.Backup>>;
+
+export function ListCardRevisions(arg1:string):Promise<Array<models.CardRevision>>;
+
+export function ListNoteTypes():Promise<Array<models.NoteType>>;
+
+export function ListProfiles():Promise<Array<string>>;
+
+export function ListTags():Promise<Array<models.Tag>>;
+
+export function ListTrash():Promise<Array<models.TrashItem>>;
+
 export function LoadConfig():Promise<config.Config>;
 
+export function MergeCards(arg1:string,arg2:string):Promise<void>;
+
+export function MoveCard(arg1:string,arg2:string,arg3:number):Promise<void>;
+
+export function MoveCards(arg1:models.CardSelection,arg2:string):Promise<number>;
+
 export function NewDeck(arg1:string):Promise<models.Deck>;
 
+export function PurgeTrash():Promise<number>;
+
+export function RemoveTagsFromCards(arg1:models.CardSelection,arg2:Array<string>):Promise<number>;
+
+export function RenameTag(arg1:string,arg2:string):Promise<number>;
+
+export function RenderCard(arg1:string):Promise<models.RenderedCard>;
+
+export function RenderCardByID(arg1:string,arg2:string):Promise<models.RenderedCard>;
+
+export function RescheduleCards(arg1:models.CardSelection,arg2:number,arg3:number):Promise<number>;
+
+export function ResetCard(arg1:string,arg2:boolean):Promise<void>;
+
+export function ResetCards(arg1:models.CardSelection,arg2:boolean):Promise<number>;
+
+export function RestoreBackup(arg1:string):Promise<void>;
+
+export function RestoreCardRevision(arg1:string,arg2:string,arg3:number):Promise<models.Flashcard>;
+
+export function RestoreFromTrash(arg1:string,arg2:string):Promise<void>;
+
 export function SaveConfig(arg1:string):Promise<void>;
 
+export function SearchCards(arg1:string,arg2:string,arg3:boolean):Promise<Array<models.Flashcard>>;
+
+export function SetAutoplayAudio(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetCardFlag(arg1:string,arg2:models.Flag):Promise<void>;
+
+export function SetCardMarked(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetCardSuspended(arg1:string,arg2:string,arg3:boolean):Promise<void>;
+
+export function SetDueDate(arg1:string,arg2:number,arg3:number):Promise<void>;
+
+export function SetNewCardOrder(arg1:string,arg2:string):Promise<void>;
+
+export function SuspendCards(arg1:models.CardSelection,arg2:boolean):Promise<number>;
+
+export function SwitchProfile(arg1:string):Promise<void>;
+
 export function ToHTML(arg1:string):Promise<string>;
 
 export function UpdateConfigFromJSON(arg1:string):Promise<void>;
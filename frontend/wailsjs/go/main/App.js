@@ -2,18 +2,94 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddImage(arg1, arg2) {
+  return window['go']['main']['App']['AddImage'](arg1, arg2);
+}
+
+export function AddImageFile(arg1) {
+  return window['go']['main']['App']['AddImageFile'](arg1);
+}
+
+export function AddNoteType(arg1) {
+  return window['go']['main']['App']['AddNoteType'](arg1);
+}
+
 export function AddOrUpdateCard(arg1, arg2, arg3, arg4) {
   return window['go']['main']['App']['AddOrUpdateCard'](arg1, arg2, arg3, arg4);
 }
 
+export function AddOrUpdateNote(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['AddOrUpdateNote'](arg1, arg2, arg3, arg4);
+}
+
+export function AddSound(arg1, arg2) {
+  return window['go']['main']['App']['AddSound'](arg1, arg2);
+}
+
+export function AddSoundFile(arg1) {
+  return window['go']['main']['App']['AddSoundFile'](arg1);
+}
+
+export function AddTagsToCards(arg1, arg2) {
+  return window['go']['main']['App']['AddTagsToCards'](arg1, arg2);
+}
+
+export function BackupNow() {
+  return window['go']['main']['App']['BackupNow']();
+}
+
+export function CardsByTag(arg1) {
+  return window['go']['main']['App']['CardsByTag'](arg1);
+}
+
+export function CheckDatabase(arg1) {
+  return window['go']['main']['App']['CheckDatabase'](arg1);
+}
+
+export function CollectMedia() {
+  return window['go']['main']['App']['CollectMedia']();
+}
+
+export function CreateProfile(arg1) {
+  return window['go']['main']['App']['CreateProfile'](arg1);
+}
+
+export function CurrentProfile() {
+  return window['go']['main']['App']['CurrentProfile']();
+}
+
 export function DeleteCardFromDeck(arg1, arg2) {
   return window['go']['main']['App']['DeleteCardFromDeck'](arg1, arg2);
 }
 
+export function DeleteCards(arg1) {
+  return window['go']['main']['App']['DeleteCards'](arg1);
+}
+
 export function DeleteDeck(arg1) {
   return window['go']['main']['App']['DeleteDeck'](arg1);
 }
 
+export function DeleteNote(arg1) {
+  return window['go']['main']['App']['DeleteNote'](arg1);
+}
+
+export function DeleteNoteType(arg1) {
+  return window['go']['main']['App']['DeleteNoteType'](arg1);
+}
+
+export function DeleteProfile(arg1) {
+  return window['go']['main']['App']['DeleteProfile'](arg1);
+}
+
+export function DiffCardRevisions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['DiffCardRevisions'](arg1, arg2, arg3);
+}
+
+export function EmptyTrash() {
+  return window['go']['main']['App']['EmptyTrash']();
+}
+
 export function EscapeHtml(arg1) {
   return window['go']['main']['App']['EscapeHtml'](arg1);
 }
@@ -22,6 +98,10 @@ export function EscapeHtmlAttribute(arg1) {
   return window['go']['main']['App']['EscapeHtmlAttribute'](arg1);
 }
 
+export function FindDuplicates(arg1, arg2) {
+  return window['go']['main']['App']['FindDuplicates'](arg1, arg2);
+}
+
 export function GenerateID() {
   return window['go']['main']['App']['GenerateID']();
 }
@@ -42,6 +122,10 @@ export function GetCardsFromDeck(arg1) {
   return window['go']['main']['App']['GetCardsFromDeck'](arg1);
 }
 
+export function GetCustomStudyCards(arg1, arg2) {
+  return window['go']['main']['App']['GetCustomStudyCards'](arg1, arg2);
+}
+
 export function GetDecks() {
   return window['go']['main']['App']['GetDecks']();
 }
@@ -50,6 +134,10 @@ export function GetFutureReviewCards() {
   return window['go']['main']['App']['GetFutureReviewCards']();
 }
 
+export function GetNote(arg1) {
+  return window['go']['main']['App']['GetNote'](arg1);
+}
+
 export function GetReviewCards() {
   return window['go']['main']['App']['GetReviewCards']();
 }
@@ -58,18 +146,138 @@ export function GetReviewCardsForDeck(arg1) {
   return window['go']['main']['App']['GetReviewCardsForDeck'](arg1);
 }
 
+export function GetReviewLog(arg1) {
+  return window['go']['main']['App']['GetReviewLog'](arg1);
+}
+
+export function ListBackups() {
+  return window['go']['main']['App']['ListBackups']();
+}
+
+export function ListCardRevisions(arg1) {
+  return window['go']['main']['App']['ListCardRevisions'](arg1);
+}
+
+export function ListNoteTypes() {
+  return window['go']['main']['App']['ListNoteTypes']();
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
+export function ListTags() {
+  return window['go']['main']['App']['ListTags']();
+}
+
+export function ListTrash() {
+  return window['go']['main']['App']['ListTrash']();
+}
+
 export function LoadConfig() {
   return window['go']['main']['App']['LoadConfig']();
 }
 
+export function MergeCards(arg1, arg2) {
+  return window['go']['main']['App']['MergeCards'](arg1, arg2);
+}
+
+export function MoveCard(arg1, arg2, arg3) {
+  return window['go']['main']['App']['MoveCard'](arg1, arg2, arg3);
+}
+
+export function MoveCards(arg1, arg2) {
+  return window['go']['main']['App']['MoveCards'](arg1, arg2);
+}
+
 export function NewDeck(arg1) {
   return window['go']['main']['App']['NewDeck'](arg1);
 }
 
+export function PurgeTrash() {
+  return window['go']['main']['App']['PurgeTrash']();
+}
+
+export function RemoveTagsFromCards(arg1, arg2) {
+  return window['go']['main']['App']['RemoveTagsFromCards'](arg1, arg2);
+}
+
+export function RenameTag(arg1, arg2) {
+  return window['go']['main']['App']['RenameTag'](arg1, arg2);
+}
+
+export function RenderCard(arg1) {
+  return window['go']['main']['App']['RenderCard'](arg1);
+}
+
+export function RenderCardByID(arg1, arg2) {
+  return window['go']['main']['App']['RenderCardByID'](arg1, arg2);
+}
+
+export function RescheduleCards(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RescheduleCards'](arg1, arg2, arg3);
+}
+
+export function ResetCard(arg1, arg2) {
+  return window['go']['main']['App']['ResetCard'](arg1, arg2);
+}
+
+export function ResetCards(arg1, arg2) {
+  return window['go']['main']['App']['ResetCards'](arg1, arg2);
+}
+
+export function RestoreBackup(arg1) {
+  return window['go']['main']['App']['RestoreBackup'](arg1);
+}
+
+export function RestoreCardRevision(arg1, arg2, arg3) {
+  return window['go']['main']['App']['RestoreCardRevision'](arg1, arg2, arg3);
+}
+
+export function RestoreFromTrash(arg1, arg2) {
+  return window['go']['main']['App']['RestoreFromTrash'](arg1, arg2);
+}
+
 export function SaveConfig(arg1) {
   return window['go']['main']['App']['SaveConfig'](arg1);
 }
 
+export function SearchCards(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SearchCards'](arg1, arg2, arg3);
+}
+
+export function SetAutoplayAudio(arg1, arg2) {
+  return window['go']['main']['App']['SetAutoplayAudio'](arg1, arg2);
+}
+
+export function SetCardFlag(arg1, arg2) {
+  return window['go']['main']['App']['SetCardFlag'](arg1, arg2);
+}
+
+export function SetCardMarked(arg1, arg2) {
+  return window['go']['main']['App']['SetCardMarked'](arg1, arg2);
+}
+
+export function SetCardSuspended(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SetCardSuspended'](arg1, arg2, arg3);
+}
+
+export function SetDueDate(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SetDueDate'](arg1, arg2, arg3);
+}
+
+export function SetNewCardOrder(arg1, arg2) {
+  return window['go']['main']['App']['SetNewCardOrder'](arg1, arg2);
+}
+
+export function SuspendCards(arg1, arg2) {
+  return window['go']['main']['App']['SuspendCards'](arg1, arg2);
+}
+
+export function SwitchProfile(arg1) {
+  return window['go']['main']['App']['SwitchProfile'](arg1);
+}
+
 export function ToHTML(arg1) {
   return window['go']['main']['App']['ToHTML'](arg1);
 }
@@ -3,4 +3,6 @@
 
 export function ExportDeck(arg1:string):Promise<string>;
 
+export function ExportNotes(arg1:string,arg2:string):Promise<string>;
+
 export function ImportDeck(arg1:string,arg2:string):Promise<void>;
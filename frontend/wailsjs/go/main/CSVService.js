@@ -6,6 +6,10 @@ export function ExportDeck(arg1) {
   return window['go']['main']['CSVService']['ExportDeck'](arg1);
 }
 
+export function ExportNotes(arg1, arg2) {
+  return window['go']['main']['CSVService']['ExportNotes'](arg1, arg2);
+}
+
 export function ImportDeck(arg1, arg2) {
   return window['go']['main']['CSVService']['ImportDeck'](arg1, arg2);
 }
@@ -5,6 +5,13 @@ export namespace config {
 	    numberOfCardsInReview: number;
 	    vimMode: boolean;
 	    lineNumbers: boolean;
+	    trashRetentionDays: number;
+	    backupCount: number;
+	    backupIntervalMinutes: number;
+	    persistRenderCache: boolean;
+	    trustedDecks: string[];
+	    allowedHtml: Record<string, string[]>;
+	    mathRendering: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -16,6 +23,32 @@ export namespace config {
 	        this.numberOfCardsInReview = source["numberOfCardsInReview"];
 	        this.vimMode = source["vimMode"];
 	        this.lineNumbers = source["lineNumbers"];
+	        this.trashRetentionDays = source["trashRetentionDays"];
+	        this.backupCount = source["backupCount"];
+	        this.backupIntervalMinutes = source["backupIntervalMinutes"];
+	        this.persistRenderCache = source["persistRenderCache"];
+	        this.trustedDecks = source["trustedDecks"];
+	        this.allowedHtml = source["allowedHtml"];
+	        this.mathRendering = source["mathRendering"];
+	    }
+	}
+
+}
+
+export namespace diff {
+	
+	export class Line {
+	    op: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Line(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.op = source["op"];
+	        this.text = source["text"];
 	    }
 	}
 
@@ -60,6 +93,22 @@ export namespace main {
 
 export namespace models {
 	
+	export class Backup {
+	    name: string;
+	    size: number;
+	    created_at: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Backup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.created_at = source["created_at"];
+	    }
+	}
 	export class CardData {
 	    last_review: number;
 	    next_review: number;
@@ -78,6 +127,56 @@ export namespace models {
 	        this.ease_factor = source["ease_factor"];
 	    }
 	}
+	export class CardRevision {
+	    id: number;
+	    cardId: string;
+	    title: string;
+	    content: string;
+	    created_at: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CardRevision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.cardId = source["cardId"];
+	        this.title = source["title"];
+	        this.content = source["content"];
+	        this.created_at = source["created_at"];
+	    }
+	}
+	export class CardSelection {
+	    cardIds: string[];
+	    query: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CardSelection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.cardIds = source["cardIds"];
+	        this.query = source["query"];
+	    }
+	}
+	export class CardTemplate {
+	    name: string;
+	    front: string;
+	    back: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CardTemplate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.front = source["front"];
+	        this.back = source["back"];
+	    }
+	}
 	export class Flashcard {
 	    deckId: string;
 	    id: string;
@@ -87,6 +186,19 @@ export namespace models {
 	    next_review?: number;
 	    review_count?: number;
 	    ease_factor?: number;
+	    suspended: boolean;
+	    flag: number;
+	    marked: boolean;
+	    noteId?: string;
+	    ord: number;
+	    sourceId?: string;
+	    cloze?: number;
+	    reverse?: boolean;
+	    sourceUrl?: string;
+	    tags: string[];
+	    created_at: number;
+	    updated_at: number;
+	    position: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Flashcard(source);
@@ -102,11 +214,28 @@ export namespace models {
 	        this.next_review = source["next_review"];
 	        this.review_count = source["review_count"];
 	        this.ease_factor = source["ease_factor"];
+	        this.suspended = source["suspended"];
+	        this.flag = source["flag"];
+	        this.marked = source["marked"];
+	        this.noteId = source["noteId"];
+	        this.ord = source["ord"];
+	        this.sourceId = source["sourceId"];
+	        this.cloze = source["cloze"];
+	        this.reverse = source["reverse"];
+	        this.sourceUrl = source["sourceUrl"];
+	        this.tags = source["tags"];
+	        this.created_at = source["created_at"];
+	        this.updated_at = source["updated_at"];
+	        this.position = source["position"];
 	    }
 	}
 	export class Deck {
 	    name: string;
 	    cards: Flashcard[];
+	    created_at: number;
+	    updated_at: number;
+	    new_card_order: string;
+	    autoplay_audio: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new Deck(source);
@@ -116,6 +245,10 @@ export namespace models {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.name = source["name"];
 	        this.cards = this.convertValues(source["cards"], Flashcard);
+	        this.created_at = source["created_at"];
+	        this.updated_at = source["updated_at"];
+	        this.new_card_order = source["new_card_order"];
+	        this.autoplay_audio = source["autoplay_audio"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -136,6 +269,237 @@ export namespace models {
 		    return a;
 		}
 	}
+	export class DuplicatePair {
+	    a: Flashcard;
+	    b: Flashcard;
+	    similarity: number;
+	    exact: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicatePair(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.a = this.convertValues(source["a"], Flashcard);
+	        this.b = this.convertValues(source["b"], Flashcard);
+	        this.similarity = source["similarity"];
+	        this.exact = source["exact"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class IntegrityIssue {
+	    kind: string;
+	    table: string;
+	    id: string;
+	    detail: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityIssue(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.table = source["table"];
+	        this.id = source["id"];
+	        this.detail = source["detail"];
+	    }
+	}
+	export class IntegrityReport {
+	    ok: boolean;
+	    integrity_check: string[];
+	    issues: IntegrityIssue[];
+	    repaired: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ok = source["ok"];
+	        this.integrity_check = source["integrity_check"];
+	        this.issues = this.convertValues(source["issues"], IntegrityIssue);
+	        this.repaired = source["repaired"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Note {
+	    id: string;
+	    noteTypeId: string;
+	    deckId: string;
+	    fields: Record<string, string>;
+	    created_at: number;
+	    updated_at: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Note(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.noteTypeId = source["noteTypeId"];
+	        this.deckId = source["deckId"];
+	        this.fields = source["fields"];
+	        this.created_at = source["created_at"];
+	        this.updated_at = source["updated_at"];
+	    }
+	}
+	export class NoteType {
+	    id: string;
+	    name: string;
+	    fields: string[];
+	    templates: CardTemplate[];
+	    builtin: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new NoteType(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.templates = this.convertValues(source["templates"], CardTemplate);
+	        this.builtin = source["builtin"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RenderedCard {
+	    front: string;
+	    back: string;
+	    extra: string[];
+	    title: string;
+	    tags: string[];
+	    autoplay: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RenderedCard(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.front = source["front"];
+	        this.back = source["back"];
+	        this.extra = source["extra"];
+	        this.title = source["title"];
+	        this.tags = source["tags"];
+	        this.autoplay = source["autoplay"];
+	    }
+	}
+	export class Review {
+	    id: number;
+	    cardId: string;
+	    reviewed_at: number;
+	    outcome: number;
+	    next_review: number;
+	    ease_factor: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Review(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.cardId = source["cardId"];
+	        this.reviewed_at = source["reviewed_at"];
+	        this.outcome = source["outcome"];
+	        this.next_review = source["next_review"];
+	        this.ease_factor = source["ease_factor"];
+	    }
+	}
+	export class Tag {
+	    name: string;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Tag(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.count = source["count"];
+	    }
+	}
+	export class TrashItem {
+	    kind: string;
+	    id: string;
+	    deckId: string;
+	    title: string;
+	    deleted_at: number;
+	    card_count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrashItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.id = source["id"];
+	        this.deckId = source["deckId"];
+	        this.title = source["title"];
+	        this.deleted_at = source["deleted_at"];
+	        this.card_count = source["card_count"];
+	    }
+	}
 
 }
 
@@ -4,7 +4,6 @@ import (
 	"embed"
 
 	"github.com/dfirebaugh/mdsrs/config"
-	"github.com/dfirebaugh/mdsrs/store"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -14,36 +13,31 @@ import (
 var assets embed.FS
 
 func main() {
-	cfg, err := config.LoadConfigFromFile(".mdsrs/config.json")
-	if err != nil {
-		cfg = config.NewConfig()
-	}
-
-	store.SetDBPath(cfg.DBFile)
-
 	app := NewApp()
 	a := &options.App{
 		Title:  "mdsrs",
 		Width:  1024,
 		Height: 768,
 		AssetServer: &assetserver.Options{
-			Assets: assets,
+			Assets:  assets,
+			Handler: &mediaHandler{app: app},
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
-		OnShutdown:       store.CloseDB,
+		OnShutdown:       app.shutdown,
 		Bind: []any{
 			app,
 			&ConfigService{
 				Config: config.NewConfig(),
+				app:    app,
 			},
 			&CSVService{
-				decks: app.decks,
+				app: app,
 			},
 		},
 	}
 
-	err = wails.Run(a)
+	err := wails.Run(a)
 	if err != nil {
 		println("Error:", err.Error())
 	}
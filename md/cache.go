@@ -0,0 +1,137 @@
+package md
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+)
+
+// renderVersion is part of every cache key. Bump it when rendering changes
+// so that HTML persisted by an older version isn't used.
+const renderVersion = "3"
+
+// DefaultCacheSize is the number of rendered documents DefaultCache keeps in
+// memory.
+const DefaultCacheSize = 2048
+
+// CacheStore persists rendered HTML so that it survives restarts.
+type CacheStore interface {
+	LoadHTML(key string) (html string, ok bool, err error)
+	SaveHTML(key string, html string) error
+}
+
+// Cache is a least-recently-used cache of rendered HTML keyed by a hash of
+// the markdown and the options it was rendered with. It is safe for
+// concurrent use. Entries that fall out of memory are still found in the
+// CacheStore, if one is set.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	store   CacheStore
+}
+
+type cacheEntry struct {
+	key  string
+	html []byte
+}
+
+// DefaultCache is the cache ToHTML and ClozeToHTML use.
+var DefaultCache = NewCache(DefaultCacheSize)
+
+// NewCache creates a cache that keeps up to size documents in memory.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// SetStore sets or, with nil, removes the store rendered HTML is persisted
+// in.
+func (c *Cache) SetStore(store CacheStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// Clear empties the in-memory cache.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of documents cached in memory.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Get returns the HTML cached for a key, looking in the store when it isn't
+// in memory. Store errors count as misses.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		html := el.Value.(*cacheEntry).html
+		c.mu.Unlock()
+		return html, true
+	}
+	store := c.store
+	c.mu.Unlock()
+
+	if store == nil {
+		return nil, false
+	}
+	html, ok, err := store.LoadHTML(key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	c.add(key, []byte(html))
+	return []byte(html), true
+}
+
+// Put caches the HTML rendered for a key, in memory and in the store.
+func (c *Cache) Put(key string, html []byte) {
+	c.add(key, html)
+
+	c.mu.Lock()
+	store := c.store
+	c.mu.Unlock()
+	if store != nil {
+		// Persisting is best effort; the HTML can always be rendered again.
+		_ = store.SaveHTML(key, string(html))
+	}
+}
+
+func (c *Cache) add(key string, html []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).html = html
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, html: html})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheKey hashes markdown together with the options it is rendered with.
+func cacheKey(source []byte, cloze Cloze, opts Options) string {
+	h := sha256.New()
+	h.Write([]byte(renderVersion + "\x00" + strconv.Itoa(cloze.Active) + "\x00" + strconv.FormatBool(cloze.Reveal) + "\x00" + opts.Math.String() + "\x00"))
+	h.Write(source)
+	return hex.EncodeToString(h.Sum(nil))
+}
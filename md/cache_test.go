@@ -0,0 +1,66 @@
+package md_test
+
+import (
+	"testing"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/store"
+)
+
+const benchmarkCard = "# Capitals\n\n" +
+	"{{c1::Paris}} is the capital of **France**, and {{c2::Berlin}} of Germany. #geography/europe\n\n" +
+	"| Country | Capital |\n|---|---|\n| France | Paris |\n| Germany | Berlin |\n\n" +
+	"```go\nfmt.Println(\"hello\")\n```\n\n" +
+	"$e^{i\\pi} + 1 = 0$ [sound:paris.mp3] :smile:\n"
+
+// BenchmarkToHTML measures a render without a cached copy, one found in
+// memory and one loaded from the SQLite render cache.
+func BenchmarkToHTML(b *testing.B) {
+	source := []byte(benchmarkCard)
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			md.DefaultCache.Clear()
+			if _, err := md.ToHTML(source, md.Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("memory", func(b *testing.B) {
+		md.DefaultCache.Clear()
+		if _, err := md.ToHTML(source, md.Options{}); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := md.ToHTML(source, md.Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("sqlite", func(b *testing.B) {
+		cache, err := store.OpenRenderCache(store.MemoryPath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer cache.Close()
+		md.DefaultCache.SetStore(cache)
+		defer md.DefaultCache.SetStore(nil)
+
+		md.DefaultCache.Clear()
+		if _, err := md.ToHTML(source, md.Options{}); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Emptying memory makes every render load the HTML from the
+			// store.
+			md.DefaultCache.Clear()
+			if _, err := md.ToHTML(source, md.Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package md
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrMultipleBacks   = errors.New("card has more than one <card-back>")
+	ErrUnclosedSection = errors.New("section is not closed")
+	ErrUnexpectedClose = errors.New("closing tag without an opening tag")
+	ErrNestedSection   = errors.New("sections cannot be nested")
+)
+
+// ParsedCard is card markdown split into its parts.
+type ParsedCard struct {
+	// Front is the question: everything outside the back and extra sections.
+	Front string
+	// Back is the answer, from the <card-back> section.
+	Back string
+	// Extra holds the <card-extra> sections, shown after the answer.
+	Extra []string
+	// Title is the first line of text on the front.
+	Title string
+	// Tags are the #tags anywhere on the card, as ExtractTags returns them.
+	Tags []string
+	// Reverse is set by a <card-reverse/> directive or reverse: true in the
+	// front matter, and asks for a second card with the front and back
+	// swapped.
+	Reverse bool
+	// Meta is the card's front matter, or nil if it has none.
+	Meta *FrontMatter
+}
+
+// Reversed returns card markdown with the front and back swapped. Extra
+// sections stay on the back.
+func (c *ParsedCard) Reversed() string {
+	var b strings.Builder
+	b.WriteString(c.Back)
+	if c.Front != "" {
+		b.WriteString("\n<card-back>\n" + c.Front + "\n</card-back>")
+	}
+	for _, extra := range c.Extra {
+		b.WriteString("\n<card-extra>\n" + extra + "\n</card-extra>")
+	}
+
+	// The reverse card shares the front matter's tags, but not the rest of
+	// it, which is about this card.
+	if c.Meta != nil && len(c.Meta.Tags) > 0 {
+		if content, err := SetFrontMatter([]byte(b.String()), &FrontMatter{Tags: c.Meta.Tags}); err == nil {
+			return string(content)
+		}
+	}
+	return b.String()
+}
+
+type sectionTag struct {
+	name    string
+	closing bool
+	start   int
+	end     int
+	line    int
+}
+
+var sectionNames = []string{"card-back", "card-extra", "card-reverse"}
+
+// ParseCard splits card markdown into front, back and extra sections. A card
+// has at most one <card-back>...</card-back> section and any number of
+// <card-extra>...</card-extra> sections, and may contain a <card-reverse/>
+// directive; tags inside code spans and fenced code blocks are ignored. Front
+// matter is parsed into Meta and left out of the sections.
+//
+// Cards written before sections were parsed are read the way they were
+// shown: a <card-back> that isn't closed runs to the end of the card, and
+// text after </card-back> outside an extra section is part of the back.
+func ParseCard(source []byte) (*ParsedCard, error) {
+	card := &ParsedCard{Extra: []string{}}
+
+	meta, _, err := ParseFrontMatter(source)
+	if err != nil {
+		return nil, err
+	}
+	card.Meta = meta
+	tags := ExtractTags(source)
+	source = blankFrontMatter(source)
+
+	sections := findSectionTags(source)
+	source, sections, card.Reverse = stripDirectives(source, sections)
+
+	var front bytes.Buffer
+	var open *sectionTag
+	sawBack := false
+	pos := 0
+
+	for _, tag := range sections {
+		if open == nil {
+			if tag.closing {
+				return nil, fmt.Errorf("line %d: </%s>: %w", tag.line, tag.name, ErrUnexpectedClose)
+			}
+			if tag.name == "card-back" && sawBack {
+				return nil, fmt.Errorf("line %d: %w", tag.line, ErrMultipleBacks)
+			}
+			text := source[pos:tag.start]
+			if sawBack {
+				card.appendBack(text)
+			} else {
+				front.Write(text)
+			}
+			open = &tag
+			pos = tag.end
+			continue
+		}
+
+		if !tag.closing {
+			if tag.name == "card-back" && (sawBack || open.name == "card-back") {
+				return nil, fmt.Errorf("line %d: %w", tag.line, ErrMultipleBacks)
+			}
+			return nil, fmt.Errorf("line %d: <%s> inside <%s>: %w", tag.line, tag.name, open.name, ErrNestedSection)
+		}
+		if tag.name != open.name {
+			return nil, fmt.Errorf("line %d: </%s>: %w", tag.line, tag.name, ErrUnexpectedClose)
+		}
+
+		body := strings.TrimSpace(string(source[pos:tag.start]))
+		if open.name == "card-back" {
+			card.Back = body
+			sawBack = true
+		} else {
+			card.Extra = append(card.Extra, body)
+		}
+		open = nil
+		pos = tag.end
+	}
+
+	switch {
+	case open != nil && open.name == "card-back":
+		card.Back = strings.TrimSpace(string(source[pos:]))
+	case open != nil:
+		return nil, fmt.Errorf("line %d: <%s>: %w", open.line, open.name, ErrUnclosedSection)
+	case sawBack:
+		card.appendBack(source[pos:])
+	default:
+		front.Write(source[pos:])
+	}
+
+	card.Front = strings.TrimSpace(front.String())
+	card.Title = firstLine(PlainText([]byte(card.Front)))
+	card.Tags = tags
+	if meta != nil {
+		card.Reverse = card.Reverse || meta.Reverse
+		if meta.Title != "" {
+			card.Title = meta.Title
+		}
+	}
+	return card, nil
+}
+
+// appendBack adds text found after </card-back> to the back, as a paragraph
+// of its own.
+func (c *ParsedCard) appendBack(text []byte) {
+	text = bytes.TrimSpace(text)
+	if len(text) == 0 {
+		return
+	}
+	if c.Back != "" {
+		c.Back += "\n\n"
+	}
+	c.Back += string(text)
+}
+
+// stripDirectives blanks out <card-reverse/> directives, keeping the offsets
+// of the other tags valid, and reports whether there were any.
+func stripDirectives(source []byte, tags []sectionTag) ([]byte, []sectionTag, bool) {
+	found := false
+	sections := tags[:0:0]
+	for _, tag := range tags {
+		if tag.name != "card-reverse" {
+			sections = append(sections, tag)
+			continue
+		}
+		if !found {
+			source = bytes.Clone(source)
+			found = true
+		}
+		for i := tag.start; i < tag.end; i++ {
+			source[i] = ' '
+		}
+	}
+	return source, sections, found
+}
+
+// findSectionTags returns the section tags in source outside of code.
+func findSectionTags(source []byte) []sectionTag {
+	var tags []sectionTag
+	var fence []byte
+	line := 1
+
+	for start := 0; start < len(source); line++ {
+		end := bytes.IndexByte(source[start:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += start + 1
+		}
+		text := source[start:end]
+
+		trimmed := bytes.TrimLeft(text, " ")
+		switch {
+		case fence != nil:
+			if bytes.HasPrefix(bytes.TrimSpace(trimmed), fence) {
+				fence = nil
+			}
+		case bytes.HasPrefix(trimmed, []byte("```")), bytes.HasPrefix(trimmed, []byte("~~~")):
+			fence = trimmed[:3]
+		default:
+			tags = append(tags, lineSectionTags(text, start, line)...)
+		}
+
+		start = end
+	}
+	return tags
+}
+
+// lineSectionTags finds section tags in one line outside of code spans.
+func lineSectionTags(text []byte, offset int, line int) []sectionTag {
+	var tags []sectionTag
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '`':
+			// Skip to the matching run of backticks, if there is one.
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			run := bytes.Repeat([]byte("`"), n)
+			if j := bytes.Index(text[i+n:], run); j >= 0 {
+				i += n + j + n - 1
+			} else {
+				i += n - 1
+			}
+		case '<':
+			if tag, ok := parseSectionTag(text[i:]); ok {
+				tag.start = offset + i
+				tag.end = tag.start + tag.end
+				tag.line = line
+				tags = append(tags, tag)
+				i += tag.end - tag.start - 1
+			}
+		}
+	}
+	return tags
+}
+
+// parseSectionTag recognizes <name>, </name> and <name/> at the start of s,
+// ignoring case. end is the length of the tag.
+func parseSectionTag(s []byte) (sectionTag, bool) {
+	end := bytes.IndexByte(s, '>')
+	if end < 0 {
+		return sectionTag{}, false
+	}
+	inner := strings.ToLower(strings.TrimSpace(string(s[1:end])))
+
+	closing := strings.HasPrefix(inner, "/")
+	inner = strings.TrimSpace(strings.TrimPrefix(inner, "/"))
+	inner = strings.TrimSpace(strings.TrimSuffix(inner, "/"))
+	for _, name := range sectionNames {
+		if inner == name {
+			return sectionTag{name: name, closing: closing, end: end + 1}, true
+		}
+	}
+	return sectionTag{}, false
+}
+
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
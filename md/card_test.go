@@ -0,0 +1,73 @@
+package md
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseCard(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		front  string
+		back   string
+		extra  []string
+	}{
+		{"front only", "# Question", "# Question", "", []string{}},
+		{"back", "Q\n<card-back>\nA\n</card-back>", "Q", "A", []string{}},
+		{
+			"extra",
+			"Q\n<card-back>\nA\n</card-back>\n<card-extra>\nmore\n</card-extra>",
+			"Q", "A", []string{"more"},
+		},
+		{"unclosed back", "Q\n<card-back>\nA\n\nstill the back", "Q", "A\n\nstill the back", []string{}},
+		{"text after back", "Q\n<card-back>\nA\n</card-back>\nafter", "Q", "A\n\nafter", []string{}},
+		{
+			"text between back and extra",
+			"Q\n<card-back>\nA\n</card-back>\nafter\n<card-extra>\nmore\n</card-extra>",
+			"Q", "A\n\nafter", []string{"more"},
+		},
+		{"tag in code", "Q `<card-back>`\n<card-back>\nA\n</card-back>", "Q `<card-back>`", "A", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := ParseCard([]byte(tt.source))
+			if err != nil {
+				t.Fatalf("ParseCard(%q) returned an error: %v", tt.source, err)
+			}
+			if card.Front != tt.front {
+				t.Errorf("Front = %q, want %q", card.Front, tt.front)
+			}
+			if card.Back != tt.back {
+				t.Errorf("Back = %q, want %q", card.Back, tt.back)
+			}
+			if !slices.Equal(card.Extra, tt.extra) {
+				t.Errorf("Extra = %q, want %q", card.Extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestParseCardErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   error
+	}{
+		{"two backs", "Q\n<card-back>\nA\n</card-back>\n<card-back>\nB\n</card-back>", ErrMultipleBacks},
+		{"back in back", "Q\n<card-back>\nA\n<card-back>\nB", ErrMultipleBacks},
+		{"extra in back", "Q\n<card-back>\nA\n<card-extra>\nB\n</card-extra>", ErrNestedSection},
+		{"unclosed extra", "Q\n<card-extra>\nmore", ErrUnclosedSection},
+		{"stray close", "Q\n</card-back>", ErrUnexpectedClose},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCard([]byte(tt.source)); !errors.Is(err, tt.want) {
+				t.Errorf("ParseCard(%q) = %v, want %v", tt.source, err, tt.want)
+			}
+		})
+	}
+}
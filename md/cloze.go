@@ -0,0 +1,278 @@
+package md
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindCloze is the kind of ClozeNode.
+var KindCloze = ast.NewNodeKind("Cloze")
+
+var kindClozeMarker = ast.NewNodeKind("ClozeMarker")
+
+// ClozeNode is a cloze deletion, {{c1::text}} or {{c1::text::hint}}. Its
+// children are the hidden text.
+type ClozeNode struct {
+	ast.BaseInline
+	// Index is the cloze number, the 1 in c1. Clozes with the same number
+	// are hidden together on the same card.
+	Index int
+	// Hint is shown in place of the hidden text, if given.
+	Hint []byte
+	// Hidden and Active are set from the Cloze options the document was
+	// parsed with: the text of a hidden cloze isn't rendered, and an active
+	// one is highlighted.
+	Hidden bool
+	Active bool
+}
+
+func (n *ClozeNode) Kind() ast.NodeKind {
+	return KindCloze
+}
+
+func (n *ClozeNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Index": strconv.Itoa(n.Index),
+		"Hint":  string(n.Hint),
+	}, nil)
+}
+
+// clozeMarker is the opening "{{cN::" or closing "::hint}}" or "}}" of a
+// cloze. The transformer replaces each matched pair with a ClozeNode; markers
+// left over render as the text they were parsed from.
+type clozeMarker struct {
+	ast.BaseInline
+	open  bool
+	index int
+	hint  []byte
+	raw   []byte
+}
+
+func (n *clozeMarker) Kind() ast.NodeKind {
+	return kindClozeMarker
+}
+
+func (n *clozeMarker) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Raw": string(n.raw)}, nil)
+}
+
+var (
+	openClozesKey   = parser.NewContextKey()
+	clozeOptionsKey = parser.NewContextKey()
+)
+
+// ClozeContext returns a parser context that renders clozes with the given
+// options instead of the extension's, so that one goldmark instance can
+// render every card.
+func ClozeContext(opts Cloze) parser.Context {
+	pc := parser.NewContext()
+	pc.Set(clozeOptionsKey, opts)
+	return pc
+}
+
+type clozeParser struct{}
+
+func (p *clozeParser) Trigger() []byte {
+	return []byte{'{', ':', '}'}
+}
+
+func (p *clozeParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	open, _ := pc.Get(openClozesKey).(int)
+
+	if bytes.HasPrefix(line, []byte("{{c")) {
+		i := 3
+		for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+			i++
+		}
+		if i == 3 || !bytes.HasPrefix(line[i:], []byte("::")) {
+			return nil
+		}
+		index, err := strconv.Atoi(string(line[3:i]))
+		if err != nil || index == 0 {
+			return nil
+		}
+		end := i + 2
+		pc.Set(openClozesKey, open+1)
+		block.Advance(end)
+		return &clozeMarker{open: true, index: index, raw: append([]byte(nil), line[:end]...)}
+	}
+
+	if open == 0 {
+		return nil
+	}
+
+	var end int
+	var hint []byte
+	switch {
+	case bytes.HasPrefix(line, []byte("}}")):
+		end = 2
+	case bytes.HasPrefix(line, []byte("::")):
+		j := bytes.Index(line[2:], []byte("}}"))
+		if j < 0 {
+			return nil
+		}
+		hint = bytes.TrimSpace(line[2 : 2+j])
+		end = 2 + j + 2
+	default:
+		return nil
+	}
+
+	pc.Set(openClozesKey, open-1)
+	block.Advance(end)
+	return &clozeMarker{hint: append([]byte(nil), hint...), raw: append([]byte(nil), line[:end]...)}
+}
+
+// clozeTransformer turns each opening marker and the closing marker that
+// follows it in the same parent into a ClozeNode around the nodes between
+// them.
+type clozeTransformer struct {
+	defaults Cloze
+}
+
+func (t *clozeTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	opts, ok := pc.Get(clozeOptionsKey).(Cloze)
+	if !ok {
+		opts = t.defaults
+	}
+
+	var parents []ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			if m, ok := n.(*clozeMarker); ok && m.open {
+				parents = append(parents, n.Parent())
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	seen := make(map[ast.Node]bool)
+	for _, parent := range parents {
+		if !seen[parent] {
+			seen[parent] = true
+			wrapClozes(parent, opts)
+		}
+	}
+}
+
+func wrapClozes(parent ast.Node, opts Cloze) {
+	var open *clozeMarker
+	for n := parent.FirstChild(); n != nil; {
+		next := n.NextSibling()
+		m, ok := n.(*clozeMarker)
+		switch {
+		case !ok:
+		case m.open:
+			open = m
+		case open != nil:
+			active := open.index == opts.Active
+			cloze := &ClozeNode{
+				Index:  open.index,
+				Hint:   m.hint,
+				Hidden: active && !opts.Reveal,
+				Active: active && opts.Reveal,
+			}
+			parent.InsertBefore(parent, open, cloze)
+			for c := open.NextSibling(); c != m; {
+				following := c.NextSibling()
+				cloze.AppendChild(cloze, c)
+				c = following
+			}
+			parent.RemoveChild(parent, open)
+			parent.RemoveChild(parent, m)
+			open = nil
+		}
+		n = next
+	}
+}
+
+// Cloze is a goldmark extension for cloze deletions. The cloze numbered
+// Active is hidden, or highlighted if Reveal is set; other clozes show their
+// text. An Active of zero shows every cloze. ClozeContext overrides these
+// options for a single conversion.
+type Cloze struct {
+	Active int
+	Reveal bool
+}
+
+func (e *Cloze) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(&clozeParser{}, 50)),
+		parser.WithASTTransformers(util.Prioritized(&clozeTransformer{defaults: *e}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&clozeRenderer{}, 500),
+	))
+}
+
+type clozeRenderer struct{}
+
+func (r *clozeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindCloze, r.renderCloze)
+	reg.Register(kindClozeMarker, r.renderMarker)
+}
+
+func (r *clozeRenderer) renderCloze(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ClozeNode)
+
+	if !entering {
+		w.WriteString("</span>")
+		return ast.WalkContinue, nil
+	}
+
+	class := "cloze"
+	if n.Active {
+		class = "cloze cloze-active"
+	}
+	if n.Hidden {
+		class = "cloze cloze-hidden"
+	}
+	fmt.Fprintf(w, `<span class="%s" data-cloze="%d">`, class, n.Index)
+
+	if n.Hidden {
+		w.WriteByte('[')
+		if len(n.Hint) > 0 {
+			w.Write(util.EscapeHTML(n.Hint))
+		} else {
+			w.WriteString("...")
+		}
+		w.WriteByte(']')
+		return ast.WalkSkipChildren, nil
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *clozeRenderer) renderMarker(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.Write(util.EscapeHTML(node.(*clozeMarker).raw))
+	}
+	return ast.WalkContinue, nil
+}
+
+// ClozeNumbers returns the distinct cloze numbers in a markdown document in
+// ascending order.
+func ClozeNumbers(source []byte) []int {
+	doc := tagParser.Parse(text.NewReader(blankFrontMatter(source)))
+
+	seen := make(map[int]bool)
+	numbers := []int{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if c, ok := n.(*ClozeNode); ok && entering && !seen[c.Index] {
+			seen[c.Index] = true
+			numbers = append(numbers, c.Index)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	sort.Ints(numbers)
+	return numbers
+}
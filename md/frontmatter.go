@@ -0,0 +1,183 @@
+package md
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DueLayout is the date format of the due key in front matter.
+const DueLayout = "2006-01-02"
+
+// FrontMatter is the YAML block a card may start with, between --- lines.
+// Keys other than the ones below are kept in Other.
+type FrontMatter struct {
+	Title string `yaml:"title,omitempty"`
+	// Tags are added to the card's #tags. A list or a string of tags
+	// separated by spaces or commas is accepted, with or without '#'.
+	Tags    TagList `yaml:"tags,omitempty"`
+	Source  string  `yaml:"source,omitempty"`
+	Reverse bool    `yaml:"reverse,omitempty"`
+	// Suspended and Marked are only applied when set, so that a card
+	// without them keeps whatever was set in the app.
+	Suspended *bool `yaml:"suspended,omitempty"`
+	Marked    *bool `yaml:"marked,omitempty"`
+	// Flag is a flag name or number.
+	Flag string `yaml:"flag,omitempty"`
+	// Due and Ease schedule a card that hasn't been reviewed yet. Due is a
+	// date in DueLayout.
+	Due  string  `yaml:"due,omitempty"`
+	Ease float64 `yaml:"ease,omitempty"`
+
+	Other map[string]any `yaml:",inline"`
+}
+
+// TagList is a list of tags that can be written in YAML as a list or as a
+// single string.
+type TagList []string
+
+func (t *TagList) UnmarshalYAML(node *yaml.Node) error {
+	var raw []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		raw = strings.FieldsFunc(node.Value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	case yaml.SequenceNode:
+		if err := node.Decode(&raw); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("line %d: tags must be a list or a string", node.Line)
+	}
+
+	*t = (*t)[:0]
+	for _, tag := range raw {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "#"); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// DueTime returns the due date as a time in the local time zone.
+func (fm *FrontMatter) DueTime() (time.Time, bool) {
+	if fm.Due == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(DueLayout, fm.Due, time.Local)
+	return t, err == nil
+}
+
+// frontMatterSplit finds a front matter block: a --- line at the very start
+// of source and the next --- or ... line. yamlStart and yamlEnd delimit the
+// YAML, and end is where the markdown after the block starts.
+func frontMatterSplit(source []byte) (yamlStart, yamlEnd, end int, ok bool) {
+	start := 0
+	if bytes.HasPrefix(source, []byte("\xef\xbb\xbf")) {
+		start = 3
+	}
+	line, yamlStart := readLine(source, start)
+	if string(line) != "---" {
+		return 0, 0, 0, false
+	}
+
+	for pos := yamlStart; pos < len(source); {
+		line, next := readLine(source, pos)
+		if s := string(line); s == "---" || s == "..." {
+			return yamlStart, pos, next, true
+		}
+		pos = next
+	}
+	return 0, 0, 0, false
+}
+
+// readLine returns the line at pos without its line ending or trailing
+// spaces, and the position of the next line.
+func readLine(source []byte, pos int) ([]byte, int) {
+	end := bytes.IndexByte(source[pos:], '\n')
+	next := len(source)
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += pos
+		next = end + 1
+	}
+	return bytes.TrimRight(source[pos:end], " \t\r"), next
+}
+
+// ParseFrontMatter splits a document into its front matter and the markdown
+// after it. fm is nil when there is no front matter.
+func ParseFrontMatter(source []byte) (fm *FrontMatter, body []byte, err error) {
+	yamlStart, yamlEnd, end, ok := frontMatterSplit(source)
+	if !ok {
+		return nil, source, nil
+	}
+
+	fm = &FrontMatter{}
+	if err := yaml.Unmarshal(source[yamlStart:yamlEnd], fm); err != nil {
+		return nil, source, fmt.Errorf("invalid front matter: %w", err)
+	}
+	if fm.Due != "" {
+		if _, ok := fm.DueTime(); !ok {
+			return nil, source, fmt.Errorf("invalid front matter: due must be a date like %s, got %q", DueLayout, fm.Due)
+		}
+	}
+	if fm.Ease < 0 {
+		return nil, source, fmt.Errorf("invalid front matter: ease must be positive, got %v", fm.Ease)
+	}
+	return fm, source[end:], nil
+}
+
+// StripFrontMatter returns the markdown after any front matter.
+func StripFrontMatter(source []byte) []byte {
+	if _, _, end, ok := frontMatterSplit(source); ok {
+		return source[end:]
+	}
+	return source
+}
+
+// blankFrontMatter replaces any front matter with spaces, keeping line breaks
+// so that offsets and line numbers into source stay valid.
+func blankFrontMatter(source []byte) []byte {
+	_, _, end, ok := frontMatterSplit(source)
+	if !ok {
+		return source
+	}
+	blanked := bytes.Clone(source)
+	for i := 0; i < end; i++ {
+		if blanked[i] != '\n' {
+			blanked[i] = ' '
+		}
+	}
+	return blanked
+}
+
+// SetFrontMatter replaces a document's front matter, adding a block if it has
+// none and removing it if fm is nil or empty.
+func SetFrontMatter(source []byte, fm *FrontMatter) ([]byte, error) {
+	body := StripFrontMatter(source)
+
+	var data bytes.Buffer
+	if fm != nil {
+		enc := yaml.NewEncoder(&data)
+		enc.SetIndent(2)
+		if err := enc.Encode(fm); err != nil {
+			return nil, fmt.Errorf("failed to write front matter: %w", err)
+		}
+		enc.Close()
+	}
+	if yml := bytes.TrimSpace(data.Bytes()); len(yml) == 0 || string(yml) == "{}" {
+		return body, nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(data.Bytes())
+	b.WriteString("---\n")
+	b.Write(body)
+	return b.Bytes(), nil
+}
@@ -0,0 +1,820 @@
+package md
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	mathjax "github.com/litao91/goldmark-mathjax"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// MathMode is how math is rendered.
+type MathMode int
+
+const (
+	// MathJaxMode leaves the TeX in the HTML for MathJax to typeset in the
+	// browser.
+	MathJaxMode MathMode = iota
+	// MathMLMode converts the TeX to MathML while rendering, so the HTML
+	// shows math without any script.
+	MathMLMode
+)
+
+var mathModeNames = map[MathMode]string{
+	MathJaxMode: "mathjax",
+	MathMLMode:  "mathml",
+}
+
+func (m MathMode) String() string {
+	return mathModeNames[m]
+}
+
+// ParseMathMode parses "mathjax" or "mathml". An empty string is
+// MathJaxMode.
+func ParseMathMode(s string) (MathMode, error) {
+	if s == "" {
+		return MathJaxMode, nil
+	}
+	for mode, name := range mathModeNames {
+		if strings.EqualFold(s, name) {
+			return mode, nil
+		}
+	}
+	return MathJaxMode, fmt.Errorf("unknown math mode %q", s)
+}
+
+var mathModeKey = parser.NewContextKey()
+
+// KindMathML is the kind of MathMLNode.
+var KindMathML = ast.NewNodeKind("MathML")
+
+// MathMLNode is TeX math to be rendered as MathML.
+type MathMLNode struct {
+	ast.BaseInline
+	TeX     string
+	Display bool
+}
+
+func (n *MathMLNode) Kind() ast.NodeKind {
+	return KindMathML
+}
+
+func (n *MathMLNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"TeX":     n.TeX,
+		"Display": fmt.Sprint(n.Display),
+	}, nil)
+}
+
+// MathML is a goldmark extension that renders the math goldmark-mathjax
+// parses as MathML when the parser context asks for MathMLMode.
+type MathML struct{}
+
+func (e *MathML) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&mathMLTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&mathMLRenderer{}, 500),
+	))
+}
+
+type mathMLTransformer struct{}
+
+func (t *mathMLTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	if mode, _ := pc.Get(mathModeKey).(MathMode); mode != MathMLMode {
+		return
+	}
+	source := reader.Source()
+
+	var nodes []ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *mathjax.InlineMath, *mathjax.MathBlock:
+			nodes = append(nodes, n)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range nodes {
+		parent := n.Parent()
+		if _, ok := n.(*mathjax.MathBlock); ok {
+			// Display math stays a block, in a paragraph like MathJax's.
+			var tex strings.Builder
+			for i := 0; i < n.Lines().Len(); i++ {
+				line := n.Lines().At(i)
+				tex.Write(line.Value(source))
+			}
+			para := ast.NewParagraph()
+			para.AppendChild(para, &MathMLNode{TeX: tex.String(), Display: true})
+			parent.ReplaceChild(parent, n, para)
+			continue
+		}
+
+		var tex strings.Builder
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if t, ok := c.(*ast.Text); ok {
+				tex.Write(t.Segment.Value(source))
+			}
+		}
+		parent.ReplaceChild(parent, n, &MathMLNode{TeX: tex.String()})
+	}
+}
+
+type mathMLRenderer struct{}
+
+func (r *mathMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathML, r.renderMathML)
+}
+
+func (r *mathMLRenderer) renderMathML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*MathMLNode)
+		w.WriteString(TeXToMathML(n.TeX, n.Display))
+	}
+	return ast.WalkContinue, nil
+}
+
+// TeXToMathML converts TeX math to a MathML <math> element that carries the
+// TeX as an annotation. It covers the commonly used subset of TeX math:
+// scripts, fractions, roots, Greek letters and symbols, fonts, accents,
+// \left...\right and matrix environments. Anything else is shown as an
+// error inside the formula rather than failing.
+func TeXToMathML(tex string, display bool) string {
+	p := &texParser{src: tex, display: display}
+	body := wrapRow(p.parseList(0))
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block" class="math display"`)
+	} else {
+		b.WriteString(` class="math inline"`)
+	}
+	b.WriteString(`><semantics>`)
+	b.WriteString(body)
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String()
+}
+
+type texParser struct {
+	src     string
+	pos     int
+	display bool
+	// variant is the math alphabet letters are written in, set by font
+	// commands such as \mathbf.
+	variant string
+}
+
+// Things parseList stops at.
+const (
+	stopBrace = 1 << iota
+	stopRight
+	stopCell
+)
+
+type atomKind int
+
+const (
+	atomOrdinary atomKind = iota
+	// atomLimits takes its scripts above and below in display math, like
+	// \sum.
+	atomLimits
+)
+
+func wrapRow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+func (p *texParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *texParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position
+// without consuming it, or "" if there isn't one.
+func (p *texParser) peekCommand() string {
+	if p.eof() || p.src[p.pos] != '\\' {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.src) && isASCIILetter(p.src[end]) {
+		end++
+	}
+	if end == p.pos+1 {
+		if end >= len(p.src) {
+			return ""
+		}
+		_, size := utf8.DecodeRuneInString(p.src[end:])
+		return p.src[end : end+size]
+	}
+	return p.src[p.pos+1 : end]
+}
+
+func (p *texParser) readCommand() string {
+	name := p.peekCommand()
+	p.pos += 1 + len(name)
+	return name
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *texParser) atStop(stops int) bool {
+	if p.eof() {
+		return true
+	}
+	switch c := p.src[p.pos]; {
+	case c == '}':
+		return stops&stopBrace != 0
+	case c == '&':
+		return stops&stopCell != 0
+	case c == '\\':
+		switch p.peekCommand() {
+		case "right":
+			return stops&stopRight != 0
+		case "\\", "end", "cr":
+			return stops&stopCell != 0
+		}
+	}
+	return false
+}
+
+// parseList parses atoms with their scripts until one of stops or the end
+// of the input.
+func (p *texParser) parseList(stops int) []string {
+	var items []string
+	for {
+		p.skipSpace()
+		if p.atStop(stops) {
+			return items
+		}
+		if p.src[p.pos] == '}' || p.src[p.pos] == '&' {
+			// Stray braces and cell separators outside a group or table.
+			p.pos++
+			continue
+		}
+		if item := p.parseScripts(); item != "" {
+			items = append(items, item)
+		}
+	}
+}
+
+// parseGroup parses {...}, with the opening brace at the current position.
+func (p *texParser) parseGroup() string {
+	p.pos++
+	items := p.parseList(stopBrace)
+	if !p.eof() {
+		p.pos++
+	}
+	if len(items) == 0 {
+		return "<mrow></mrow>"
+	}
+	return wrapRow(items)
+}
+
+// parseArg parses a command argument or script: a group or a single atom.
+func (p *texParser) parseArg() string {
+	p.skipSpace()
+	if p.eof() {
+		return "<mrow></mrow>"
+	}
+	if p.src[p.pos] == '{' {
+		return p.parseGroup()
+	}
+	atom, _ := p.parseAtom(true)
+	if atom == "" {
+		return "<mrow></mrow>"
+	}
+	return atom
+}
+
+// parseTextArg returns the raw text of a {...} argument, or of the next
+// character.
+func (p *texParser) parseTextArg() string {
+	p.skipSpace()
+	if p.eof() {
+		return ""
+	}
+	if p.src[p.pos] != '{' {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		s := p.src[p.pos : p.pos+size]
+		p.pos += size
+		return s
+	}
+	depth := 0
+	start := p.pos + 1
+	for i := p.pos; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				p.pos = i + 1
+				return p.src[start:i]
+			}
+		}
+	}
+	p.pos = len(p.src)
+	return p.src[start:]
+}
+
+func (p *texParser) parseOptionalArg() (string, bool) {
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '[' {
+		return "", false
+	}
+	p.pos++
+	items := []string{}
+	for {
+		p.skipSpace()
+		if p.eof() || p.src[p.pos] == ']' {
+			break
+		}
+		if item := p.parseScripts(); item != "" {
+			items = append(items, item)
+		}
+	}
+	if !p.eof() {
+		p.pos++
+	}
+	return wrapRow(items), true
+}
+
+func (p *texParser) parseScripts() string {
+	base, kind := p.parseAtom(false)
+
+	var sub, sup string
+	hasSub, hasSup := false, false
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		if c := p.src[p.pos]; c == '^' && !hasSup {
+			p.pos++
+			sup, hasSup = p.parseArg(), true
+		} else if c == '_' && !hasSub {
+			p.pos++
+			sub, hasSub = p.parseArg(), true
+		} else if c == '\'' && !hasSup {
+			p.pos++
+			primes := "′"
+			for !p.eof() && p.src[p.pos] == '\'' {
+				p.pos++
+				primes += "′"
+			}
+			sup, hasSup = "<mo>"+primes+"</mo>", true
+		} else {
+			break
+		}
+	}
+	if !hasSub && !hasSup {
+		return base
+	}
+	if base == "" {
+		base = "<mrow></mrow>"
+	}
+
+	limits := kind == atomLimits && p.display
+	switch {
+	case hasSub && hasSup && limits:
+		return "<munderover>" + base + sub + sup + "</munderover>"
+	case hasSub && hasSup:
+		return "<msubsup>" + base + sub + sup + "</msubsup>"
+	case hasSub && limits:
+		return "<munder>" + base + sub + "</munder>"
+	case hasSub:
+		return "<msub>" + base + sub + "</msub>"
+	case limits:
+		return "<mover>" + base + sup + "</mover>"
+	default:
+		return "<msup>" + base + sup + "</msup>"
+	}
+}
+
+// parseAtom parses one atom. single limits numbers to one digit, as in
+// script arguments.
+func (p *texParser) parseAtom(single bool) (string, atomKind) {
+	p.skipSpace()
+	if p.eof() {
+		return "", atomOrdinary
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		return p.parseGroup(), atomOrdinary
+	case c == '\\':
+		return p.parseCommand()
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		start := p.pos
+		p.pos++
+		for !single && !p.eof() && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + html.EscapeString(mathVariant(p.src[start:p.pos], p.variant)) + "</mn>", atomOrdinary
+	case c == '~':
+		p.pos++
+		return `<mspace width="0.3333em"></mspace>`, atomOrdinary
+	case c == '^' || c == '_':
+		// Scripts with nothing to attach to; parseScripts gives them an
+		// empty base.
+		return "", atomOrdinary
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	s := string(r)
+	if unicode.IsLetter(r) {
+		return p.identifier(s), atomOrdinary
+	}
+	switch s {
+	case "-":
+		s = "−"
+	case "*":
+		s = "∗"
+	}
+	return "<mo>" + html.EscapeString(s) + "</mo>", atomOrdinary
+}
+
+func (p *texParser) identifier(s string) string {
+	if p.variant == "normal" {
+		return `<mi mathvariant="normal">` + html.EscapeString(s) + "</mi>"
+	}
+	return "<mi>" + html.EscapeString(mathVariant(s, p.variant)) + "</mi>"
+}
+
+func (p *texParser) parseCommand() (string, atomKind) {
+	name := p.readCommand()
+	if name == "" {
+		p.pos++
+		return "", atomOrdinary
+	}
+
+	if s, ok := texIdentifiers[name]; ok {
+		if unicode.IsUpper([]rune(s)[0]) && p.variant == "" {
+			// Capital Greek letters are upright.
+			return `<mi mathvariant="normal">` + s + "</mi>", atomOrdinary
+		}
+		return p.identifier(s), atomOrdinary
+	}
+	if s, ok := texOperators[name]; ok {
+		return "<mo>" + html.EscapeString(s) + "</mo>", atomOrdinary
+	}
+	if s, ok := texBigOperators[name]; ok {
+		kind := atomLimits
+		if strings.Contains(name, "int") {
+			kind = atomOrdinary
+		}
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", kind
+	}
+	if texFunctions[name] {
+		kind := atomOrdinary
+		if texLimitFunctions[name] {
+			kind = atomLimits
+		}
+		return "<mi>" + name + "</mi>", kind
+	}
+	if width, ok := texSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, atomOrdinary
+	}
+	if accent, ok := texAccents[name]; ok {
+		stretchy := "false"
+		if strings.HasPrefix(name, "wide") || strings.HasPrefix(name, "over") {
+			stretchy = "true"
+		}
+		return `<mover accent="true">` + p.parseArg() + `<mo stretchy="` + stretchy + `">` + accent + "</mo></mover>", atomOrdinary
+	}
+	if variant, ok := texFonts[name]; ok {
+		outer := p.variant
+		p.variant = variant
+		arg := p.parseArg()
+		p.variant = outer
+		return arg, atomOrdinary
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.parseArg()
+		den := p.parseArg()
+		return "<mfrac>" + num + den + "</mfrac>", atomOrdinary
+	case "binom", "dbinom", "tbinom":
+		top := p.parseArg()
+		bottom := p.parseArg()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + `</mfrac><mo>)</mo></mrow>`, atomOrdinary
+	case "sqrt":
+		if index, ok := p.parseOptionalArg(); ok {
+			return "<mroot>" + p.parseArg() + index + "</mroot>", atomOrdinary
+		}
+		return "<msqrt>" + p.parseArg() + "</msqrt>", atomOrdinary
+	case "text", "textrm", "textit", "textbf", "textsf", "texttt", "mbox", "hbox":
+		return "<mtext>" + html.EscapeString(strings.ReplaceAll(p.parseTextArg(), "\\", "")) + "</mtext>", atomOrdinary
+	case "operatorname":
+		return `<mi mathvariant="normal">` + html.EscapeString(p.parseTextArg()) + "</mi>", atomLimits
+	case "underline":
+		return `<munder accentunder="true">` + p.parseArg() + `<mo stretchy="true">_</mo></munder>`, atomOrdinary
+	case "overbrace":
+		return "<mover>" + p.parseArg() + `<mo stretchy="true">⏞</mo></mover>`, atomLimits
+	case "underbrace":
+		return "<munder>" + p.parseArg() + `<mo stretchy="true">⏟</mo></munder>`, atomLimits
+	case "left":
+		open := p.parseDelimiter()
+		items := p.parseList(stopRight)
+		closing := ""
+		if p.peekCommand() == "right" {
+			p.readCommand()
+			closing = p.parseDelimiter()
+		}
+		return "<mrow>" + fence(open) + strings.Join(items, "") + fence(closing) + "</mrow>", atomOrdinary
+	case "right":
+		// A \right without a \left.
+		p.parseDelimiter()
+		return "", atomOrdinary
+	case "begin":
+		return p.parseEnvironment(p.parseTextArg()), atomOrdinary
+	case "not":
+		p.skipSpace()
+		if !p.eof() && p.src[p.pos] == '=' {
+			p.pos++
+			return "<mo>≠</mo>", atomOrdinary
+		}
+		if next := p.peekCommand(); next == "in" {
+			p.readCommand()
+			return "<mo>∉</mo>", atomOrdinary
+		}
+		return "", atomOrdinary
+	case "pmod":
+		return "<mrow><mo>(</mo><mi>mod</mi><mspace width=\"0.3333em\"></mspace>" + p.parseArg() + "<mo>)</mo></mrow>", atomOrdinary
+	case "end":
+		// An \end without a \begin.
+		p.parseTextArg()
+		return "", atomOrdinary
+	case "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits", "\\", "cr":
+		return "", atomOrdinary
+	}
+	return "<merror><mtext>\\" + html.EscapeString(name) + "</mtext></merror>", atomOrdinary
+}
+
+// parseDelimiter parses the delimiter after \left or \right.
+func (p *texParser) parseDelimiter() string {
+	p.skipSpace()
+	if p.eof() {
+		return ""
+	}
+	if p.src[p.pos] == '\\' {
+		name := p.readCommand()
+		if s, ok := texOperators[name]; ok {
+			return s
+		}
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if r == '.' {
+		return ""
+	}
+	return string(r)
+}
+
+func fence(delim string) string {
+	if delim == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(delim) + "</mo>"
+}
+
+// environments maps the matrix-like environments to their delimiters.
+var environments = map[string][2]string{
+	"matrix":   {"", ""},
+	"pmatrix":  {"(", ")"},
+	"bmatrix":  {"[", "]"},
+	"Bmatrix":  {"{", "}"},
+	"vmatrix":  {"|", "|"},
+	"Vmatrix":  {"‖", "‖"},
+	"cases":    {"{", ""},
+	"aligned":  {"", ""},
+	"align":    {"", ""},
+	"align*":   {"", ""},
+	"gathered": {"", ""},
+	"split":    {"", ""},
+	"array":    {"", ""},
+}
+
+func (p *texParser) parseEnvironment(name string) string {
+	delims, ok := environments[name]
+	if !ok {
+		// Skip to the end of the unknown environment.
+		end := `\end{` + name + `}`
+		if i := strings.Index(p.src[p.pos:], end); i >= 0 {
+			p.pos += i + len(end)
+		} else {
+			p.pos = len(p.src)
+		}
+		return "<merror><mtext>" + html.EscapeString(name) + "</mtext></merror>"
+	}
+	if name == "array" {
+		p.parseTextArg()
+	}
+
+	align := ""
+	switch name {
+	case "cases":
+		align = ` columnalign="left"`
+	case "aligned", "align", "align*", "split":
+		align = ` columnalign="right left right left"`
+	}
+
+	var rows []string
+	var cells []string
+	for {
+		cell := p.parseList(stopCell | stopBrace)
+		cells = append(cells, "<mtd>"+wrapRow(cell)+"</mtd>")
+
+		if p.eof() {
+			break
+		}
+		if p.src[p.pos] == '}' {
+			// A brace that doesn't belong to the table.
+			p.pos++
+			continue
+		}
+		if p.src[p.pos] == '&' {
+			p.pos++
+			continue
+		}
+		cmd := p.readCommand()
+		if cmd == "end" {
+			p.parseTextArg()
+			break
+		}
+		rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+		cells = nil
+	}
+	if len(cells) > 1 || len(cells) == 1 && cells[0] != "<mtd><mrow></mrow></mtd>" {
+		rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+	}
+
+	table := "<mtable" + align + ">" + strings.Join(rows, "") + "</mtable>"
+	if delims[0] == "" && delims[1] == "" {
+		return table
+	}
+	return "<mrow>" + fence(delims[0]) + table + fence(delims[1]) + "</mrow>"
+}
+
+// mathVariant writes letters and digits in a Unicode math alphabet.
+// MathML Core only supports mathvariant="normal", so the other alphabets
+// are written with their own characters.
+func mathVariant(s string, variant string) string {
+	alphabet, ok := mathAlphabets[variant]
+	if !ok {
+		return s
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		if special, ok := alphabet.exceptions[r]; ok {
+			b.WriteRune(special)
+			continue
+		}
+		switch {
+		case r >= 'A' && r <= 'Z' && alphabet.upper != 0:
+			b.WriteRune(alphabet.upper + r - 'A')
+		case r >= 'a' && r <= 'z' && alphabet.lower != 0:
+			b.WriteRune(alphabet.lower + r - 'a')
+		case r >= '0' && r <= '9' && alphabet.digits != 0:
+			b.WriteRune(alphabet.digits + r - '0')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+type mathAlphabet struct {
+	upper, lower, digits rune
+	// exceptions are letters encoded outside the alphabet's block.
+	exceptions map[rune]rune
+}
+
+var mathAlphabets = map[string]mathAlphabet{
+	"bold":        {upper: 0x1D400, lower: 0x1D41A, digits: 0x1D7CE},
+	"italic":      {upper: 0x1D434, lower: 0x1D44E, exceptions: map[rune]rune{'h': 'ℎ'}},
+	"bold-italic": {upper: 0x1D468, lower: 0x1D482},
+	"script": {upper: 0x1D49C, lower: 0x1D4B6, exceptions: map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ',
+		'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"fraktur": {upper: 0x1D504, lower: 0x1D51E, exceptions: map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+	"double-struck": {upper: 0x1D538, lower: 0x1D552, digits: 0x1D7D8, exceptions: map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"sans-serif": {upper: 0x1D5A0, lower: 0x1D5BA, digits: 0x1D7E2},
+	"monospace":  {upper: 0x1D670, lower: 0x1D68A, digits: 0x1D7F6},
+}
+
+var texFonts = map[string]string{
+	"mathbf":     "bold",
+	"bf":         "bold",
+	"mathit":     "italic",
+	"boldsymbol": "bold-italic",
+	"bm":         "bold-italic",
+	"mathcal":    "script",
+	"mathscr":    "script",
+	"mathfrak":   "fraktur",
+	"mathbb":     "double-struck",
+	"mathsf":     "sans-serif",
+	"mathtt":     "monospace",
+	"mathrm":     "normal",
+	"rm":         "normal",
+}
+
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "ell": "ℓ", "hbar": "ℏ", "emptyset": "∅",
+	"varnothing": "∅", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ", "wp": "℘", "$": "$", "_": "_",
+}
+
+var texOperators = map[string]string{
+	"times": "×", "cdot": "⋅", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗", "star": "⋆",
+	"circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖", "otimes": "⊗", "odot": "⊙",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "ll": "≪", "gg": "≫",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "iff": "⟺", "implies": "⟹",
+	"mapsto": "↦", "longrightarrow": "⟶", "longleftarrow": "⟵", "uparrow": "↑", "downarrow": "↓",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "forall": "∀", "exists": "∃",
+	"nexists": "∄", "neg": "¬", "lnot": "¬", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"vert": "|", "lvert": "|", "rvert": "|", "Vert": "‖", "lVert": "‖", "rVert": "‖", "|": "‖",
+	"mid": "∣", "parallel": "∥", "perp": "⊥", "angle": "∠", "triangle": "△", "prime": "′",
+	"colon": ":", "therefore": "∴", "because": "∵", "bmod": "mod",
+	"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "lbrack": "[", "rbrack": "]",
+	"%": "%", "#": "#", "&": "&",
+}
+
+var texBigOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭",
+	"oint": "∮", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁", "bigotimes": "⨂",
+	"bigvee": "⋁", "bigwedge": "⋀",
+}
+
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "lg": true, "exp": true, "lim": true, "limsup": true,
+	"liminf": true, "max": true, "min": true, "sup": true, "inf": true, "det": true,
+	"gcd": true, "deg": true, "dim": true, "ker": true, "arg": true, "Pr": true, "hom": true,
+}
+
+// texLimitFunctions take their subscripts below in display math.
+var texLimitFunctions = map[string]bool{
+	"lim": true, "limsup": true, "liminf": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "gcd": true, "Pr": true,
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", "!": "-0.1667em",
+	" ": "0.3333em", "quad": "1em", "qquad": "2em",
+}
+
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"overrightarrow": "→", "dot": "˙", "ddot": "¨", "tilde": "~", "widetilde": "~",
+	"check": "ˇ", "breve": "˘", "acute": "´", "grave": "`",
+}
@@ -0,0 +1,142 @@
+package md
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// mathMLBody returns the MathML TeXToMathML wrapped in <math>, <semantics>
+// and the TeX annotation.
+func mathMLBody(t *testing.T, out string) string {
+	t.Helper()
+	start := strings.Index(out, "<semantics>")
+	end := strings.Index(out, "<annotation")
+	if start < 0 || end < start {
+		t.Fatalf("no <semantics> body in %q", out)
+	}
+	return out[start+len("<semantics>") : end]
+}
+
+func TestTeXToMathML(t *testing.T) {
+	tests := []struct {
+		name string
+		tex  string
+		want string
+	}{
+		{"fraction", `\frac{a}{b}`, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{"superscript", `x^2`, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{"subscript and superscript", `x_i^2`, `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`},
+		{"square root", `\sqrt{x}`, `<msqrt><mi>x</mi></msqrt>`},
+		{"nth root", `\sqrt[3]{x}`, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{
+			"left right",
+			`\left( x \right)`,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mi>x</mi><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+		{
+			"matrix environment",
+			`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mtable>` +
+				`<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>` +
+				`<mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr>` +
+				`</mtable><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+		{"unknown command", `\foo`, `<merror><mtext>\foo</mtext></merror>`},
+		{"truncated fraction", `\frac{`, `<mfrac><mrow></mrow><mrow></mrow></mfrac>`},
+		{"truncated superscript", `x^`, `<msup><mi>x</mi><mrow></mrow></msup>`},
+		{"truncated subscript", `a_`, `<msub><mi>a</mi><mrow></mrow></msub>`},
+		{"trailing backslash", `\`, `<mrow></mrow>`},
+		{"truncated root", `\sqrt[`, `<mroot><mrow></mrow><mrow></mrow></mroot>`},
+		{"unclosed left", `\left(`, `<mrow><mo fence="true" stretchy="true">(</mo></mrow>`},
+		{
+			"unclosed environment",
+			`\begin{pmatrix}`,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mtable></mtable><mo fence="true" stretchy="true">)</mo></mrow>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := TeXToMathML(tt.tex, false)
+			if got := mathMLBody(t, out); got != tt.want {
+				t.Errorf("TeXToMathML(%q) = %s, want %s", tt.tex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeXToMathMLDisplay(t *testing.T) {
+	if out := TeXToMathML(`x`, true); !strings.Contains(out, `display="block"`) {
+		t.Errorf("display math isn't a block: %s", out)
+	}
+	if out := TeXToMathML(`x`, false); strings.Contains(out, `display="block"`) {
+		t.Errorf("inline math is a block: %s", out)
+	}
+}
+
+func TestToHTMLMathMode(t *testing.T) {
+	source := []byte(`$x^2$`)
+
+	mathjax, err := ToHTML(source, Options{Math: MathJaxMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(mathjax), "<math") {
+		t.Errorf("MathJaxMode rendered MathML: %s", mathjax)
+	}
+
+	mathml, err := ToHTML(source, Options{Math: MathMLMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(mathml), "<msup>") {
+		t.Errorf("MathMLMode didn't render MathML: %s", mathml)
+	}
+}
+
+func FuzzTeXToMathML(f *testing.F) {
+	for _, seed := range []string{
+		`\frac{a}{b}`, `x_i^2`, `\sqrt[3]{x}`, `\left( x \right)`, `\left\{ \right.`,
+		`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, `\begin{cases} 1 & x > 0 \\ 0 \end{cases}`,
+		`\mathbb{R}`, `\hat{x}`, `\text{a < b}`, `\sum_{i=0}^n`, `\lim_{x \to 0}`,
+		`\foo`, `\frac{`, `x^`, `\`, `}`, `\begin{`, `\sqrt[`, `<&>"`,
+	} {
+		f.Add(seed, false)
+	}
+	f.Fuzz(func(t *testing.T, tex string, display bool) {
+		if !xmlText(tex) {
+			// Characters XML can't hold are passed through for the webview
+			// to deal with.
+			t.Skip()
+		}
+		out := TeXToMathML(tex, display)
+		if !strings.HasPrefix(out, "<math") || !strings.HasSuffix(out, "</math>") {
+			t.Fatalf("TeXToMathML(%q) isn't a <math> element: %s", tex, out)
+		}
+		// The output is embedded in card HTML, so it must be well formed.
+		d := xml.NewDecoder(strings.NewReader(out))
+		for {
+			_, err := d.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("TeXToMathML(%q) isn't well formed: %v\n%s", tex, err, out)
+			}
+		}
+	})
+}
+
+// xmlText reports whether s only has characters allowed in XML.
+func xmlText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF
+	}) < 0
+}
@@ -2,10 +2,11 @@ package md
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"regexp"
+	"html"
 
-	"github.com/alecthomas/chroma/v2/formatters/html"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	mathjax "github.com/litao91/goldmark-mathjax"
 	"github.com/yuin/goldmark"
 	emoji "github.com/yuin/goldmark-emoji"
@@ -38,64 +39,106 @@ func (r *CustomHeadingRenderer) renderHeading(w util.BufWriter, source []byte, n
 	return ast.WalkContinue, nil
 }
 
-func ToHTML(source []byte) []byte {
-	markdown := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,
-			extension.NewTypographer(
-				extension.WithTypographicSubstitutions(extension.TypographicSubstitutions{
-					extension.LeftSingleQuote:  []byte("&sbquo;"),
-					extension.RightSingleQuote: nil,
-				}),
-			),
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("monokai"),
-				highlighting.WithFormatOptions(
-					html.WithLineNumbers(true),
-				),
-			),
-			extension.NewLinkify(
-				extension.WithLinkifyAllowedProtocols([][]byte{
-					[]byte("http:"),
-					[]byte("https:"),
-				}),
-				extension.WithLinkifyURLRegexp(
-					xurls.Strict(),
-				),
-			),
-			&mermaid.Extender{},
-			&hashtag.Extender{},
-			emoji.Emoji,
-			mathjax.MathJax,
+// markdown renders every document. goldmark instances are safe for
+// concurrent use, and the cloze options and math mode vary per call through
+// the parser context.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.NewTypographer(
+			extension.WithTypographicSubstitutions(extension.TypographicSubstitutions{
+				extension.LeftSingleQuote:  []byte("&sbquo;"),
+				extension.RightSingleQuote: nil,
+			}),
 		),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("monokai"),
+			highlighting.WithFormatOptions(
+				chromahtml.WithLineNumbers(true),
+			),
 		),
-		goldmark.WithRendererOptions(
-			renderer.WithNodeRenderers(
-				util.Prioritized(&CustomHeadingRenderer{}, 500),
+		extension.NewLinkify(
+			extension.WithLinkifyAllowedProtocols([][]byte{
+				[]byte("http:"),
+				[]byte("https:"),
+			}),
+			extension.WithLinkifyURLRegexp(
+				xurls.Strict(),
 			),
-			rhtml.WithHardWraps(),
-			rhtml.WithXHTML(),
-			rhtml.WithUnsafe(),
 		),
-	)
+		&mermaid.Extender{},
+		&hashtag.Extender{},
+		emoji.Emoji,
+		mathjax.MathJax,
+		&Cloze{},
+		&Media{},
+		&Sound{},
+		&MathML{},
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&CustomHeadingRenderer{}, 500),
+		),
+		rhtml.WithHardWraps(),
+		rhtml.WithXHTML(),
+		rhtml.WithUnsafe(),
+	),
+)
 
-	var buf bytes.Buffer
-	if err := markdown.Convert(source, &buf); err != nil {
-		panic(err)
-	}
+// ErrRender is wrapped by the errors ToHTML and ClozeToHTML return.
+var ErrRender = errors.New("failed to render markdown")
 
-	html := buf.String()
-	html = processImages(html)
+// Options are the settings that vary between renders. The zero value
+// renders math for MathJax.
+type Options struct {
+	// Math is how math is rendered.
+	Math MathMode
+}
 
-	return []byte(html)
+// ToHTML renders markdown to HTML.
+func ToHTML(source []byte, opts Options) ([]byte, error) {
+	return convert(source, Cloze{}, opts)
 }
 
-func processImages(html string) string {
-	re := regexp.MustCompile(`!\[(.*?)\]\((.*?)\)`)
+// ClozeToHTML renders a cloze card: cloze number index is hidden on the
+// front, and highlighted on the back.
+func ClozeToHTML(source []byte, index int, back bool, opts Options) ([]byte, error) {
+	return convert(source, Cloze{Active: index, Reveal: back}, opts)
+}
+
+// ErrorHTML renders a rendering error as a block that can be shown in place
+// of the markdown that failed.
+func ErrorHTML(err error) []byte {
+	return []byte(`<div class="render-error"><strong>Could not render this card</strong><pre>` +
+		html.EscapeString(err.Error()) + `</pre></div>`)
+}
+
+// convert renders markdown, or returns the HTML DefaultCache has for it.
+// Errors and panics from goldmark and its extensions are returned as errors
+// wrapping ErrRender, and aren't cached.
+func convert(source []byte, cloze Cloze, opts Options) (out []byte, err error) {
+	key := cacheKey(source, cloze, opts)
+	if cached, ok := DefaultCache.Get(key); ok {
+		return cached, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, fmt.Errorf("%w: %v", ErrRender, r)
+		}
+	}()
 
-	html = re.ReplaceAllString(html, `<img src="$2" alt="$1" />`)
+	var buf bytes.Buffer
+	pc := ClozeContext(cloze)
+	pc.Set(mathModeKey, opts.Math)
+	if err := markdown.Convert(StripFrontMatter(source), &buf, parser.WithContext(pc)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRender, err)
+	}
 
-	return html
+	out = buf.Bytes()
+	DefaultCache.Put(key, out)
+	return out, nil
 }
@@ -0,0 +1,49 @@
+package md
+
+import (
+	"testing"
+)
+
+// fuzzSeeds are markdown documents that exercise the extensions ToHTML and
+// ClozeToHTML add to goldmark.
+var fuzzSeeds = []string{
+	"",
+	"# Heading\n\nSome *text* with `code`.",
+	"{{c1::Paris}} is the capital of {{c2::France::country}}.",
+	"{{c1::nested {{c2::cloze}}}}",
+	"{{c1::unterminated",
+	"{{c::no number}} {{c0::zero}} {{c99999999999999999999::big}}",
+	"---\ntags: [a, b]\n---\nbody",
+	"---\nunterminated front matter",
+	"$x^2$ and $$\\frac{a}{b}$$",
+	"$\\frac{$",
+	"[sound:clip.mp3] ![image](pic.png) [sound:",
+	"#tag #nested/tag",
+	"```go\nfunc main() {}\n```",
+	"```mermaid\ngraph TD; A-->B\n```",
+	"| a | b |\n|---|---|\n| 1 | 2 |",
+	"<div>raw <script>alert(1)</script></div>",
+	"https://example.com :smile:",
+}
+
+func FuzzToHTML(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		if _, err := ToHTML([]byte(source), Options{}); err != nil {
+			t.Errorf("ToHTML(%q) returned an error: %v", source, err)
+		}
+	})
+}
+
+func FuzzClozeToHTML(f *testing.F) {
+	for i, seed := range fuzzSeeds {
+		f.Add(seed, i%3, i%2 == 0)
+	}
+	f.Fuzz(func(t *testing.T, source string, index int, back bool) {
+		if _, err := ClozeToHTML([]byte(source), index, back, Options{}); err != nil {
+			t.Errorf("ClozeToHTML(%q, %d, %t) returned an error: %v", source, index, back, err)
+		}
+	})
+}
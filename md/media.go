@@ -0,0 +1,53 @@
+package md
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// MediaPrefix is the URL path the app serves media files under.
+const MediaPrefix = "/media/"
+
+// Media is a goldmark extension that points images with relative paths at
+// the media files served under MediaPrefix, so ![diagram](abc.png) shows the
+// stored file abc.png.
+type Media struct{}
+
+func (e *Media) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(util.Prioritized(&mediaTransformer{}, 100)),
+	)
+}
+
+type mediaTransformer struct{}
+
+func (t *mediaTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			img.Destination = MediaURL(img.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// MediaURL returns the URL a media reference is served at. A reference may
+// be a bare file name or start with ./ or media/. URLs with a scheme,
+// absolute paths and fragments are returned as they are.
+func MediaURL(ref []byte) []byte {
+	if len(ref) == 0 || ref[0] == '/' || ref[0] == '#' {
+		return ref
+	}
+	if u, err := url.Parse(string(ref)); err != nil || u.Scheme != "" || u.Host != "" {
+		return ref
+	}
+
+	ref = bytes.TrimPrefix(ref, []byte("./"))
+	ref = bytes.TrimPrefix(ref, []byte("media/"))
+	return append([]byte(MediaPrefix), ref...)
+}
@@ -0,0 +1,53 @@
+package md
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// PlainText returns the text of a markdown document with the markup and any
+// raw HTML removed. Block boundaries become newlines.
+func PlainText(source []byte) string {
+	source = blankFrontMatter(source)
+	doc := tagParser.Parse(text.NewReader(source))
+
+	var b strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering {
+				b.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() || n.HardLineBreak() {
+					b.WriteByte('\n')
+				}
+			}
+		case *ast.String:
+			if entering {
+				b.Write(n.Value)
+			}
+		case *clozeMarker:
+			if entering {
+				b.Write(n.raw)
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering {
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					line := lines.At(i)
+					b.Write(line.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		if !entering && n.Type() == ast.TypeBlock && b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return b.String()
+}
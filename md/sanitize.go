@@ -0,0 +1,247 @@
+package md
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Policy is an allowlist of the HTML elements, attributes and URL schemes
+// Sanitize keeps. Elements that aren't allowed are removed but their text is
+// kept, except for elements like <script> that are removed with everything in
+// them.
+type Policy struct {
+	elements map[string]map[string]bool
+	global   map[string]bool
+	schemes  map[string]bool
+}
+
+// dropContent lists the elements removed together with their content.
+var dropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "noscript": true, "noembed": true,
+	"template": true, "textarea": true, "title": true, "xmp": true, "plaintext": true,
+	"select": true, "svg": true, "annotation-xml": true,
+}
+
+// urlAttributes are checked against the policy's URL schemes.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true, "formaction": true,
+	"poster": true, "background": true, "longdesc": true, "srcset": true,
+}
+
+// NewPolicy returns a policy that allows nothing.
+func NewPolicy() *Policy {
+	return &Policy{
+		elements: make(map[string]map[string]bool),
+		global:   make(map[string]bool),
+		schemes:  make(map[string]bool),
+	}
+}
+
+// DefaultPolicy allows the markup ToHTML produces, including card sections,
+// clozes, sounds, mermaid blocks, MathJax, MathML and Chroma highlighting,
+// and common formatting tags written by hand. Scripts, event handlers,
+// embedded documents and javascript: URLs are removed.
+func DefaultPolicy() *Policy {
+	p := NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "code",
+		"ul", "li", "dl", "dt", "dd", "table", "thead", "tbody", "tfoot", "tr",
+		"div", "span", "em", "strong", "b", "i", "u", "s", "del", "ins", "sub", "sup",
+		"mark", "kbd", "samp", "var", "small", "q", "cite", "abbr", "summary",
+		"figure", "figcaption",
+		"card-back", "card-extra", "card-reverse",
+	)
+	p.AllowAttributes("", "class", "id", "style", "title", "lang", "dir", "data-cloze")
+	p.AllowAttributes("a", "href")
+	p.AllowAttributes("img", "src", "alt", "width", "height")
+	p.AllowAttributes("audio", "src", "controls", "preload")
+	p.AllowAttributes("ol", "start", "reversed", "type")
+	p.AllowAttributes("th", "align", "colspan", "rowspan")
+	p.AllowAttributes("td", "align", "colspan", "rowspan")
+	p.AllowAttributes("details", "open")
+	// Task list items render as disabled checkboxes.
+	p.AllowAttributes("input", "type", "checked", "disabled")
+	// MathML, as TeXToMathML writes it.
+	p.AllowElements(
+		"semantics", "mrow", "mi", "mn", "mo", "mtext", "mspace", "msub", "msup", "msubsup",
+		"munder", "mover", "munderover", "mfrac", "msqrt", "mroot", "mtable", "mtr", "mtd",
+		"merror", "mstyle", "mpadded", "mphantom",
+	)
+	p.AllowAttributes("math", "xmlns", "display")
+	p.AllowAttributes("annotation", "encoding")
+	p.AllowAttributes("mi", "mathvariant")
+	p.AllowAttributes("mo", "fence", "stretchy", "largeop", "movablelimits", "lspace", "rspace")
+	p.AllowAttributes("mspace", "width")
+	p.AllowAttributes("mfrac", "linethickness")
+	p.AllowAttributes("mover", "accent")
+	p.AllowAttributes("munder", "accentunder")
+	p.AllowAttributes("mtable", "columnalign")
+	p.AllowURLSchemes("http", "https", "mailto")
+	return p
+}
+
+// AllowElements allows elements with only the global attributes.
+func (p *Policy) AllowElements(names ...string) *Policy {
+	for _, name := range names {
+		p.AllowAttributes(name)
+	}
+	return p
+}
+
+// AllowAttributes allows an element and the given attributes on it. An
+// empty element name allows the attributes on every allowed element.
+func (p *Policy) AllowAttributes(element string, attrs ...string) *Policy {
+	allowed := p.global
+	if element != "" {
+		element = strings.ToLower(element)
+		if p.elements[element] == nil {
+			p.elements[element] = make(map[string]bool)
+		}
+		allowed = p.elements[element]
+	}
+	for _, attr := range attrs {
+		allowed[strings.ToLower(attr)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes allows URLs with the given schemes. Relative URLs are
+// always allowed.
+func (p *Policy) AllowURLSchemes(schemes ...string) *Policy {
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(scheme)] = true
+	}
+	return p
+}
+
+// Sanitize returns source with everything the policy doesn't allow removed.
+func (p *Policy) Sanitize(source []byte) []byte {
+	var out bytes.Buffer
+	z := xhtml.NewTokenizer(bytes.NewReader(source))
+	skip := ""
+	depth := 0
+
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			// The tokenizer only fails at the end of input, since it reads
+			// from memory.
+			return out.Bytes()
+		}
+		tok := z.Token()
+
+		if skip != "" {
+			switch {
+			case tt == xhtml.StartTagToken && tok.Data == skip:
+				depth++
+			case tt == xhtml.EndTagToken && tok.Data == skip:
+				if depth--; depth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case xhtml.TextToken:
+			out.WriteString(html.EscapeString(tok.Data))
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if dropContent[tok.Data] {
+				if tt == xhtml.StartTagToken {
+					skip, depth = tok.Data, 1
+				}
+				continue
+			}
+			if !p.allowsTag(tok) {
+				continue
+			}
+			p.writeTag(&out, tok, tt == xhtml.SelfClosingTagToken)
+		case xhtml.EndTagToken:
+			if _, ok := p.elements[tok.Data]; ok {
+				out.WriteString("</" + tok.Data + ">")
+			}
+		}
+	}
+}
+
+func (p *Policy) allowsTag(tok xhtml.Token) bool {
+	if _, ok := p.elements[tok.Data]; !ok {
+		return false
+	}
+	if tok.Data == "input" {
+		for _, attr := range tok.Attr {
+			if attr.Key == "type" {
+				return strings.EqualFold(attr.Val, "checkbox")
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (p *Policy) writeTag(out *bytes.Buffer, tok xhtml.Token, selfClosing bool) {
+	out.WriteString("<" + tok.Data)
+	for _, attr := range tok.Attr {
+		if attr.Namespace != "" || !p.allowsAttribute(tok.Data, attr) {
+			continue
+		}
+		out.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if selfClosing {
+		out.WriteString(" />")
+	} else {
+		out.WriteString(">")
+	}
+}
+
+func (p *Policy) allowsAttribute(element string, attr xhtml.Attribute) bool {
+	if !p.global[attr.Key] && !p.elements[element][attr.Key] {
+		return false
+	}
+	switch {
+	case urlAttributes[attr.Key]:
+		return p.allowsURL(attr.Val)
+	case attr.Key == "style":
+		return safeStyle(attr.Val)
+	}
+	return true
+}
+
+// allowsURL reports whether a URL is relative or has an allowed scheme.
+func (p *Policy) allowsURL(url string) bool {
+	// Browsers ignore whitespace and control characters in schemes.
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, url)
+
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	return p.schemes[strings.ToLower(url[:colon])]
+}
+
+// safeStyle rejects inline styles that can load resources or run code.
+// Chroma writes its colors as inline styles, so they can't be dropped
+// altogether.
+func safeStyle(style string) bool {
+	s := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '\\' {
+			return -1
+		}
+		return r
+	}, style))
+	for _, bad := range []string{"url(", "expression(", "javascript:", "@import", "behavior:", "-moz-binding", "/*"} {
+		if strings.Contains(s, bad) {
+			return false
+		}
+	}
+	return true
+}
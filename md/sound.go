@@ -0,0 +1,89 @@
+package md
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindSound is the kind of SoundNode.
+var KindSound = ast.NewNodeKind("Sound")
+
+// SoundNode is a [sound:file.mp3] reference to an audio file in the media
+// directory.
+type SoundNode struct {
+	ast.BaseInline
+	// Source is the file name, as written.
+	Source []byte
+}
+
+func (n *SoundNode) Kind() ast.NodeKind {
+	return KindSound
+}
+
+func (n *SoundNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Source": string(n.Source)}, nil)
+}
+
+var soundPrefix = []byte("[sound:")
+
+type soundParser struct{}
+
+func (p *soundParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *soundParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, soundPrefix) {
+		return nil
+	}
+	end := bytes.IndexAny(line, "]\n")
+	if end < 0 || line[end] != ']' {
+		return nil
+	}
+	source := bytes.TrimSpace(line[len(soundPrefix):end])
+	if len(source) == 0 {
+		return nil
+	}
+
+	block.Advance(end + 1)
+	return &SoundNode{Source: bytes.Clone(source)}
+}
+
+// Sound is a goldmark extension that renders [sound:file.mp3] as an audio
+// player for the media file.
+type Sound struct{}
+
+func (e *Sound) Extend(m goldmark.Markdown) {
+	// Sound references have to be parsed before links, which also start
+	// with '['.
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(&soundParser{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&soundRenderer{}, 500),
+	))
+}
+
+type soundRenderer struct{}
+
+func (r *soundRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindSound, r.renderSound)
+}
+
+func (r *soundRenderer) renderSound(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*SoundNode)
+	w.WriteString(`<audio class="sound" controls preload="metadata" src="`)
+	w.Write(util.EscapeHTML(util.URLEscape(MediaURL(n.Source), true)))
+	w.WriteString(`"></audio>`)
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,169 @@
+package md
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	mathjax "github.com/litao91/goldmark-mathjax"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/hashtag"
+)
+
+// tagParser only needs to recognize hashtags, but includes the extensions
+// that change what counts as text (code spans, math, links) so that a #tag
+// is found exactly where ToHTML would render one. It also parses clozes, for
+// ClozeNumbers and PlainText, and sound references, which PlainText leaves
+// out.
+var tagParser = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		&hashtag.Extender{},
+		mathjax.MathJax,
+		&Cloze{},
+		&Sound{},
+	),
+).Parser()
+
+type tagSpan struct {
+	tag        string
+	start, end int // byte offsets of "#tag" in the source
+}
+
+func findTags(source []byte) []tagSpan {
+	source = blankFrontMatter(source)
+	doc := tagParser.Parse(text.NewReader(source))
+
+	var spans []tagSpan
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		tag, ok := n.(*hashtag.Node)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		if t, ok := tag.FirstChild().(*ast.Text); ok {
+			spans = append(spans, tagSpan{
+				tag:   string(tag.Tag),
+				start: t.Segment.Start,
+				end:   t.Segment.Stop,
+			})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	return spans
+}
+
+// ExtractTags returns the distinct #tags in a markdown document, and the
+// tags listed in its front matter, without the leading '#', in sorted order.
+// Tags are compared case-insensitively and the first spelling wins.
+func ExtractTags(source []byte) []string {
+	var found []string
+	if fm, _, err := ParseFrontMatter(source); err == nil && fm != nil {
+		found = append(found, fm.Tags...)
+	}
+	for _, span := range findTags(source) {
+		found = append(found, span.tag)
+	}
+
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, tag := range found {
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
+	})
+	return tags
+}
+
+// RenameTag replaces every #oldTag in source with #newTag, and oldTag in the
+// front matter's tags with newTag.
+func RenameTag(source []byte, oldTag, newTag string) []byte {
+	source = rewriteFrontMatterTags(source, oldTag, newTag)
+	return rewriteTags(source, oldTag, func(out *bytes.Buffer) {
+		out.WriteByte('#')
+		out.WriteString(newTag)
+	})
+}
+
+// RemoveTag deletes every #tag in source along with one adjacent space, and
+// the tag from the front matter's tags.
+func RemoveTag(source []byte, tag string) []byte {
+	source = rewriteFrontMatterTags(source, tag, "")
+	return rewriteTags(source, tag, nil)
+}
+
+// rewriteFrontMatterTags renames tag in the front matter's tags, or removes
+// it if newTag is empty. The front matter is only rewritten if it lists tag.
+func rewriteFrontMatterTags(source []byte, tag, newTag string) []byte {
+	fm, _, err := ParseFrontMatter(source)
+	if err != nil || fm == nil {
+		return source
+	}
+
+	changed := false
+	tags := TagList{}
+	for _, t := range fm.Tags {
+		if !strings.EqualFold(t, tag) {
+			tags = append(tags, t)
+			continue
+		}
+		changed = true
+		if newTag != "" {
+			tags = append(tags, newTag)
+		}
+	}
+	if !changed {
+		return source
+	}
+
+	fm.Tags = tags
+	rewritten, err := SetFrontMatter(source, fm)
+	if err != nil {
+		return source
+	}
+	return rewritten
+}
+
+func rewriteTags(source []byte, tag string, replace func(out *bytes.Buffer)) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, span := range findTags(source) {
+		if !strings.EqualFold(span.tag, tag) {
+			continue
+		}
+
+		start, end := span.start, span.end
+		if replace == nil {
+			if end < len(source) && source[end] == ' ' {
+				end++
+			} else if start > last && source[start-1] == ' ' {
+				start--
+			}
+		}
+
+		out.Write(source[last:start])
+		if replace != nil {
+			replace(&out)
+		}
+		last = end
+	}
+
+	if last == 0 {
+		return source
+	}
+	out.Write(source[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/store"
+)
+
+// mediaRetention is how long a media file nothing refers to is kept, so
+// that a file added to a card that hasn't been saved yet isn't collected.
+const mediaRetention = 24 * time.Hour
+
+// AddImage stores image data, such as an image pasted from the clipboard,
+// and returns markdown that shows it. name may be empty; when it has an
+// extension the image is stored with it.
+func (a *App) AddImage(name string, data []byte) (string, error) {
+	mediaName, err := a.addMedia(name, data, "image")
+	if err != nil {
+		return "", err
+	}
+
+	alt := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if alt == "" || alt == "." {
+		alt = "image"
+	}
+	return fmt.Sprintf("![%s](%s)", alt, mediaName), nil
+}
+
+// AddImageFile stores an image file and returns markdown that shows it.
+func (a *App) AddImageFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	return a.AddImage(filepath.Base(path), data)
+}
+
+// AddSound stores audio data and returns the [sound:...] markdown that plays
+// it. Like AddImage, name may be empty.
+func (a *App) AddSound(name string, data []byte) (string, error) {
+	mediaName, err := a.addMedia(name, data, "audio")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[sound:%s]", mediaName), nil
+}
+
+// AddSoundFile stores an audio file and returns markdown that plays it.
+func (a *App) AddSoundFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read audio: %w", err)
+	}
+	return a.AddSound(filepath.Base(path), data)
+}
+
+// addMedia stores a media file of a kind of content type, such as "image",
+// and returns its media name.
+func (a *App) addMedia(name string, data []byte, kind string) (string, error) {
+	if ext := filepath.Ext(name); ext != "" && !strings.HasPrefix(store.MediaType(ext), kind+"/") {
+		return "", fmt.Errorf("not a supported %s file: %s", kind, name)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	mediaName, err := a.store.AddMedia(name, data)
+	if err != nil {
+		return "", err
+	}
+	// A detected type can still be the wrong kind; the file is left for
+	// CollectMedia.
+	if !strings.HasPrefix(store.MediaType(mediaName), kind+"/") {
+		return "", fmt.Errorf("not a supported %s file: %s", kind, name)
+	}
+	return mediaName, nil
+}
+
+// CollectMedia deletes media files that no card or backup has referred to
+// for a day, and returns how many were deleted.
+func (a *App) CollectMedia() (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.CollectMedia(time.Now().Add(-mediaRetention).Unix())
+}
+
+// mediaHandler serves the active profile's media files under
+// md.MediaPrefix to the webview.
+type mediaHandler struct {
+	app *App
+}
+
+func (h *mediaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, md.MediaPrefix)
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	contentType := store.MediaType(name)
+	if contentType == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.app.mu.RLock()
+	path, err := h.app.store.MediaPath(name)
+	h.app.mu.RUnlock()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// SVG files can contain scripts, which must not run if one is opened
+	// directly. ServeContent answers range requests, which audio players
+	// use to seek.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
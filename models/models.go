@@ -1,5 +1,12 @@
 package models
 
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
 type Flashcard struct {
 	DeckID      string  `json:"deckId"`
 	ID          string  `json:"id"`
@@ -9,6 +16,174 @@ type Flashcard struct {
 	NextReview  int64   `json:"next_review,omitempty"`
 	ReviewCount int64   `json:"review_count,omitempty"`
 	EaseFactor  float64 `json:"ease_factor,omitempty"`
+	Suspended   bool    `json:"suspended"`
+	Flag        Flag    `json:"flag"`
+	Marked      bool    `json:"marked"`
+	// NoteID is the note the card was generated from, if any, and Ord the
+	// index of the note type's template that generated it.
+	NoteID string `json:"noteId,omitempty"`
+	Ord    int    `json:"ord"`
+	// SourceID is the card a generated card was made from, and Cloze the
+	// cloze number the card asks for, if it is a cloze card. Reverse is set
+	// on the card generated with the front and back of its source swapped.
+	SourceID string `json:"sourceId,omitempty"`
+	Cloze    int    `json:"cloze,omitempty"`
+	Reverse  bool   `json:"reverse,omitempty"`
+	// SourceURL is where the card's material came from, from the source key
+	// of its front matter.
+	SourceURL string   `json:"sourceUrl,omitempty"`
+	Tags      []string `json:"tags"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+	Position  int      `json:"position"`
+}
+
+// Flag is a colored flag set on a card during review.
+type Flag int
+
+const (
+	FlagNone Flag = iota
+	FlagRed
+	FlagOrange
+	FlagGreen
+	FlagBlue
+	FlagPink
+	FlagTurquoise
+	FlagPurple
+)
+
+// FlagNames are the names of the flags, indexed by Flag.
+var FlagNames = []string{"none", "red", "orange", "green", "blue", "pink", "turquoise", "purple"}
+
+func (f Flag) Valid() bool {
+	return f >= FlagNone && int(f) < len(FlagNames)
+}
+
+// ParseFlag accepts a flag's number or name, so "1" and "red" are the same.
+func ParseFlag(value string) (Flag, error) {
+	if i := slices.Index(FlagNames, strings.ToLower(value)); i >= 0 {
+		return Flag(i), nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || !Flag(n).Valid() {
+		return FlagNone, fmt.Errorf("unknown flag %q", value)
+	}
+	return Flag(n), nil
+}
+
+// NoteType defines the fields of a kind of note and the cards generated
+// from it, one per template.
+type NoteType struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Fields    []string       `json:"fields"`
+	Templates []CardTemplate `json:"templates"`
+	// Builtin note types can't be changed or deleted.
+	Builtin bool `json:"builtin"`
+}
+
+// CardTemplate renders a note's fields into the front and back of a card.
+// {{Field}} inserts a field, and {{#Field}}...{{/Field}} or
+// {{^Field}}...{{/Field}} include their contents only if the field is set
+// or empty, respectively.
+type CardTemplate struct {
+	Name  string `json:"name"`
+	Front string `json:"front"`
+	Back  string `json:"back"`
+}
+
+// Note holds the field values that one or more cards are generated from.
+type Note struct {
+	ID         string            `json:"id"`
+	NoteTypeID string            `json:"noteTypeId"`
+	DeckID     string            `json:"deckId"`
+	Fields     map[string]string `json:"fields"`
+	CreatedAt  int64             `json:"created_at"`
+	UpdatedAt  int64             `json:"updated_at"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type CardRevision struct {
+	ID        int64  `json:"id"`
+	CardID    string `json:"cardId"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// TrashItem is a deleted deck or card that can still be restored.
+type TrashItem struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id"`
+	DeckID    string `json:"deckId"`
+	Title     string `json:"title"`
+	DeletedAt int64  `json:"deleted_at"`
+	CardCount int    `json:"card_count"`
+}
+
+type Backup struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// IntegrityIssue is an inconsistent row found by a database check.
+type IntegrityIssue struct {
+	Kind   string `json:"kind"`
+	Table  string `json:"table"`
+	ID     string `json:"id"`
+	Detail string `json:"detail"`
+}
+
+type IntegrityReport struct {
+	OK bool `json:"ok"`
+	// IntegrityCheck holds the problems reported by SQLite itself.
+	IntegrityCheck []string         `json:"integrity_check"`
+	Issues         []IntegrityIssue `json:"issues"`
+	Repaired       bool             `json:"repaired"`
+}
+
+// CardSelection picks the cards a bulk operation applies to: those matching
+// Query if it is set, otherwise those listed in CardIDs.
+type CardSelection struct {
+	CardIDs []string `json:"cardIds"`
+	Query   string   `json:"query"`
+}
+
+// DuplicatePair is two cards whose fronts are the same or nearly so.
+type DuplicatePair struct {
+	A Flashcard `json:"a"`
+	B Flashcard `json:"b"`
+	// Similarity is the Jaccard similarity of the normalized fronts, from 0
+	// to 1.
+	Similarity float64 `json:"similarity"`
+	// Exact is set when the normalized fronts are identical.
+	Exact bool `json:"exact"`
+}
+
+// Review is an entry in a card's review log.
+type Review struct {
+	ID         int64            `json:"id"`
+	CardID     string           `json:"cardId"`
+	ReviewedAt int64            `json:"reviewed_at"`
+	Outcome    ReviewConfidence `json:"outcome"`
+	NextReview int64            `json:"next_review"`
+	EaseFactor float64          `json:"ease_factor"`
+}
+
+// RenderedCard is a card's front, back and extra sections rendered to HTML.
+type RenderedCard struct {
+	Front string   `json:"front"`
+	Back  string   `json:"back"`
+	Extra []string `json:"extra"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	// Autoplay is set when the card's deck plays sounds automatically.
+	Autoplay bool `json:"autoplay"`
 }
 
 type CardData struct {
@@ -19,11 +194,24 @@ type CardData struct {
 }
 
 type Deck struct {
-	Name    string      `json:"name"`
-	Cards   []Flashcard `json:"cards"`
-	DirPath string      `json:"-"`
+	Name      string      `json:"name"`
+	Cards     []Flashcard `json:"cards"`
+	DirPath   string      `json:"-"`
+	CreatedAt int64       `json:"created_at"`
+	UpdatedAt int64       `json:"updated_at"`
+	// NewCardOrder is how new cards are introduced: NewCardOrderPosition or
+	// NewCardOrderRandom.
+	NewCardOrder string `json:"new_card_order"`
+	// AutoplayAudio plays a card's sounds when its front is shown and when
+	// its back is revealed.
+	AutoplayAudio bool `json:"autoplay_audio"`
 }
 
+const (
+	NewCardOrderPosition = "position"
+	NewCardOrderRandom   = "random"
+)
+
 type ReviewConfidence int
 
 const (
@@ -37,6 +225,12 @@ type SRS interface {
 	GetReviewCards(numCards int) []Flashcard
 	GetReviewCardsForDecks(deckNames []string, numCards int) []Flashcard
 	GetFutureReviewCards() []Flashcard
+	GetCustomStudyCards(query string, numCards int) ([]Flashcard, error)
 	GetCardData(cardID string) CardData
 	UpdateCardData(cardID string, data CardData)
+	ResetCard(cardID string, keepLog bool) error
+	ResetCards(cardIDs []string, keepLog bool) (int, error)
+	SetDueDate(cardID string, from, to int64) error
+	SetDueDates(cardIDs []string, from, to int64) (int, error)
+	GetReviewLog(cardID string) ([]Review, error)
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+func (a *App) ListNoteTypes() ([]models.NoteType, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.ListNoteTypes()
+}
+
+// AddNoteType creates a note type with the given fields and card templates.
+func (a *App) AddNoteType(noteType models.NoteType) (models.NoteType, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.AddNoteType(noteType)
+}
+
+func (a *App) DeleteNoteType(noteTypeID string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.DeleteNoteType(noteTypeID)
+}
+
+func (a *App) GetNote(noteID string) (models.Note, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.store.GetNote(noteID)
+}
+
+// AddOrUpdateNote saves a note and regenerates its cards. An empty noteID
+// creates a new note.
+func (a *App) AddOrUpdateNote(deckID string, noteID string, noteTypeID string, fields map[string]string) (models.Note, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deck := a.decks[deckID]
+	if deck == nil {
+		return models.Note{}, fmt.Errorf("deck not found: %s", deckID)
+	}
+
+	n, err := a.store.AddOrUpdateNote(deck, models.Note{
+		ID:         noteID,
+		NoteTypeID: noteTypeID,
+		Fields:     fields,
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, a.reloadDecks()
+}
+
+// DeleteNote moves every card of a note to the trash.
+func (a *App) DeleteNote(noteID string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, err := a.store.DeleteNote(noteID)
+	if err != nil {
+		return 0, err
+	}
+	return n, a.reloadDecks()
+}
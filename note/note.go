@@ -0,0 +1,298 @@
+// Package note turns notes, sets of named fields, into cards using the
+// templates of their note type.
+package note
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// Built-in note type IDs.
+const (
+	// TypeMarkdown is a single markdown document with an optional
+	// <card-back> section, like cards written without a note.
+	TypeMarkdown = "markdown"
+	// TypeBasic has Front, Back, Extra and Source fields and makes one card.
+	TypeBasic = "basic"
+	// TypeBasicReversed is TypeBasic with a second card asking for the front
+	// from the back.
+	TypeBasicReversed = "basic-reversed"
+)
+
+const maxTitleLength = 80
+
+// extraAndSource follows the answer on the back of basic cards.
+const extraAndSource = "{{#Extra}}\n\n{{Extra}}{{/Extra}}{{#Source}}\n\n*Source: {{Source}}*{{/Source}}"
+
+var builtinTypes = []models.NoteType{
+	{
+		ID:     TypeMarkdown,
+		Name:   "Markdown",
+		Fields: []string{"Content"},
+		Templates: []models.CardTemplate{
+			{Name: "Card", Front: "{{Content}}"},
+		},
+		Builtin: true,
+	},
+	{
+		ID:     TypeBasic,
+		Name:   "Basic",
+		Fields: []string{"Front", "Back", "Extra", "Source"},
+		Templates: []models.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}" + extraAndSource},
+		},
+		Builtin: true,
+	},
+	{
+		ID:     TypeBasicReversed,
+		Name:   "Basic (and reversed card)",
+		Fields: []string{"Front", "Back", "Extra", "Source"},
+		Templates: []models.CardTemplate{
+			{Name: "Card 1", Front: "{{Front}}", Back: "{{Back}}" + extraAndSource},
+			{Name: "Card 2", Front: "{{Back}}", Back: "{{Front}}" + extraAndSource},
+		},
+		Builtin: true,
+	},
+}
+
+// BuiltinTypes returns the note types every collection has.
+func BuiltinTypes() []models.NoteType {
+	types := make([]models.NoteType, len(builtinTypes))
+	for i, nt := range builtinTypes {
+		nt.Fields = append([]string(nil), nt.Fields...)
+		nt.Templates = append([]models.CardTemplate(nil), nt.Templates...)
+		types[i] = nt
+	}
+	return types
+}
+
+// Builtin returns the built-in note type with the given ID.
+func Builtin(id string) (models.NoteType, bool) {
+	for _, nt := range BuiltinTypes() {
+		if nt.ID == id {
+			return nt, true
+		}
+	}
+	return models.NoteType{}, false
+}
+
+// Validate checks that a note type has a name, distinct field names and at
+// least one template, and that its templates only use its fields.
+func Validate(nt models.NoteType) error {
+	if strings.TrimSpace(nt.Name) == "" {
+		return fmt.Errorf("note type name cannot be empty")
+	}
+	if len(nt.Fields) == 0 {
+		return fmt.Errorf("note type %s has no fields", nt.Name)
+	}
+	if len(nt.Templates) == 0 {
+		return fmt.Errorf("note type %s has no card templates", nt.Name)
+	}
+
+	seen := make(map[string]bool, len(nt.Fields))
+	for _, field := range nt.Fields {
+		if field == "" || strings.ContainsAny(field, "{}#^/") {
+			return fmt.Errorf("invalid field name %q", field)
+		}
+		key := strings.ToLower(field)
+		if seen[key] {
+			return fmt.Errorf("duplicate field %q", field)
+		}
+		seen[key] = true
+	}
+
+	for _, tmpl := range nt.Templates {
+		for _, source := range []string{tmpl.Front, tmpl.Back} {
+			tokens, err := parse(source)
+			if err != nil {
+				return fmt.Errorf("template %s: %w", tmpl.Name, err)
+			}
+			if field := unknownField(tokens, seen); field != "" {
+				return fmt.Errorf("template %s uses unknown field %q", tmpl.Name, field)
+			}
+		}
+		if strings.TrimSpace(tmpl.Front) == "" {
+			return fmt.Errorf("template %s has an empty front", tmpl.Name)
+		}
+	}
+	return nil
+}
+
+// unknownField returns the first field used in tokens that isn't in known.
+func unknownField(tokens []token, known map[string]bool) string {
+	for _, t := range tokens {
+		if t.kind != tokenText && !known[strings.ToLower(t.field)] {
+			return t.field
+		}
+		if field := unknownField(t.children, known); field != "" {
+			return field
+		}
+	}
+	return ""
+}
+
+// Card renders template ord of a note type. ok is false when the front comes
+// out empty, in which case no card should exist for the template. The
+// content has the back in a <card-back> section, as cards written by hand
+// do.
+func Card(nt models.NoteType, ord int, fields map[string]string) (title string, content string, ok bool) {
+	if ord < 0 || ord >= len(nt.Templates) {
+		return "", "", false
+	}
+	tmpl := nt.Templates[ord]
+
+	front := strings.TrimSpace(Render(tmpl.Front, fields))
+	if front == "" {
+		return "", "", false
+	}
+
+	content = front
+	if back := strings.TrimSpace(Render(tmpl.Back, fields)); back != "" {
+		content += "\n<card-back>\n" + back + "\n</card-back>"
+	}
+
+	return Title(front), content, true
+}
+
+// Title makes a card title from the first line of text in some markdown.
+func Title(source string) string {
+	if card, err := md.ParseCard([]byte(source)); err == nil {
+		source = card.Front
+	} else if i := strings.Index(source, "<card-back>"); i >= 0 {
+		source = source[:i]
+	}
+
+	for _, line := range strings.Split(md.PlainText([]byte(source)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > maxTitleLength {
+			line = string([]rune(line)[:maxTitleLength-1]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// Render fills in a template with field values. Fields are matched
+// case-insensitively, and unknown or malformed placeholders render as
+// nothing.
+func Render(template string, fields map[string]string) string {
+	tokens, err := parse(template)
+	if err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	render(&b, tokens, fields)
+	return b.String()
+}
+
+func render(b *strings.Builder, tokens []token, fields map[string]string) {
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenText:
+			b.WriteString(t.text)
+		case tokenField:
+			b.WriteString(lookup(fields, t.field))
+		case tokenSection, tokenInverted:
+			set := strings.TrimSpace(lookup(fields, t.field)) != ""
+			if set == (t.kind == tokenSection) {
+				render(b, t.children, fields)
+			}
+		}
+	}
+}
+
+func lookup(fields map[string]string, name string) string {
+	if v, ok := fields[name]; ok {
+		return v
+	}
+	for k, v := range fields {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenField
+	tokenSection
+	tokenInverted
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	field    string
+	children []token
+}
+
+// parse splits a template into text, fields and sections.
+func parse(template string) ([]token, error) {
+	tokens, _, err := parseUntil(template, "")
+	return tokens, err
+}
+
+// parseUntil parses up to the {{/closing}} tag of a section, returning what
+// follows it. An empty closing parses to the end of the template.
+func parseUntil(s string, closing string) ([]token, string, error) {
+	var tokens []token
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			if closing != "" {
+				return nil, "", fmt.Errorf("section {{#%s}} is not closed", closing)
+			}
+			if s != "" {
+				tokens = append(tokens, token{kind: tokenText, text: s})
+			}
+			return tokens, "", nil
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated {{ in template")
+		}
+		end += start
+
+		if start > 0 {
+			tokens = append(tokens, token{kind: tokenText, text: s[:start]})
+		}
+		tag := strings.TrimSpace(s[start+2 : end])
+		s = s[end+2:]
+
+		switch {
+		case strings.HasPrefix(tag, "/"):
+			name := strings.TrimSpace(tag[1:])
+			if closing == "" || !strings.EqualFold(name, closing) {
+				return nil, "", fmt.Errorf("unexpected {{/%s}}", name)
+			}
+			return tokens, s, nil
+		case strings.HasPrefix(tag, "#"), strings.HasPrefix(tag, "^"):
+			kind := tokenSection
+			if tag[0] == '^' {
+				kind = tokenInverted
+			}
+			name := strings.TrimSpace(tag[1:])
+			children, rest, err := parseUntil(s, name)
+			if err != nil {
+				return nil, "", err
+			}
+			tokens = append(tokens, token{kind: kind, field: name, children: children})
+			s = rest
+		case tag == "":
+			return nil, "", fmt.Errorf("empty {{}} in template")
+		default:
+			tokens = append(tokens, token{kind: tokenField, field: tag})
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/srs"
+	"github.com/dfirebaugh/mdsrs/store"
+	"github.com/sirupsen/logrus"
+)
+
+// openProfile opens a profile's config and database and makes it the active
+// collection. The caller must hold a.mu for writing, or be NewApp. If the
+// profile can't be opened the current one stays open.
+func (a *App) openProfile(name string) error {
+	cfg, err := a.profiles.LoadConfig(name)
+	if err != nil {
+		return fmt.Errorf("failed to load config for profile %s: %w", name, err)
+	}
+
+	st, err := store.Open(cfg.DBFile)
+	if err != nil {
+		return fmt.Errorf("failed to open database for profile %s: %w", name, err)
+	}
+
+	if cfg.BackupCount > 0 {
+		err := st.StartBackups(store.BackupOptions{
+			Dir:      store.BackupDir(cfg.DBFile),
+			Keep:     cfg.BackupCount,
+			Interval: time.Duration(max(cfg.BackupIntervalMinutes, 0)) * time.Minute,
+		})
+		if err != nil {
+			logrus.Errorf("Failed to start backups: %v", err)
+		}
+	}
+
+	var renderCache *store.RenderCache
+	if cfg.PersistRenderCache {
+		renderCache, err = store.OpenRenderCache(store.RenderCachePath(cfg.DBFile))
+		if err != nil {
+			logrus.Errorf("Failed to open render cache: %v", err)
+		} else if n, err := renderCache.Prune(store.RenderCacheSize); err != nil {
+			logrus.Errorf("Failed to prune render cache: %v", err)
+		} else if n > 0 {
+			logrus.Infof("Pruned %d documents from the render cache", n)
+		}
+	}
+	if renderCache != nil {
+		md.DefaultCache.SetStore(renderCache)
+	} else {
+		md.DefaultCache.SetStore(nil)
+	}
+
+	mathMode, err := md.ParseMathMode(cfg.MathRendering)
+	if err != nil {
+		logrus.Errorf("Invalid mathRendering in config: %v", err)
+	}
+
+	old, oldRenderCache := a.store, a.renderCache
+	a.Config = cfg
+	a.htmlPolicy = htmlPolicy(cfg)
+	a.mathMode = mathMode
+	a.store = st
+	a.renderCache = renderCache
+	a.srs = srs.NewSRS("", st)
+	a.profile = name
+
+	if err := old.Close(); err != nil {
+		logrus.Errorf("Error closing database: %v", err)
+	}
+	if err := oldRenderCache.Close(); err != nil {
+		logrus.Errorf("Error closing render cache: %v", err)
+	}
+
+	if n, err := a.purgeTrash(); err != nil {
+		logrus.Errorf("Failed to purge trash: %v", err)
+	} else if n > 0 {
+		logrus.Infof("Purged %d cards from the trash", n)
+	}
+
+	if n, err := st.CollectMedia(time.Now().Add(-mediaRetention).Unix()); err != nil {
+		logrus.Errorf("Failed to collect media: %v", err)
+	} else if n > 0 {
+		logrus.Infof("Deleted %d unused media files", n)
+	}
+
+	if err := a.profiles.SetActive(name); err != nil {
+		logrus.Errorf("Failed to remember active profile: %v", err)
+	}
+
+	return a.reloadDecks()
+}
+
+// configPath returns the config file of the active profile.
+func (a *App) configPath() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.profiles.ConfigPath(a.profile)
+}
+
+func (a *App) ListProfiles() ([]string, error) {
+	return a.profiles.List()
+}
+
+func (a *App) CurrentProfile() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.profile
+}
+
+// CreateProfile creates an empty collection. It does not switch to it.
+func (a *App) CreateProfile(name string) error {
+	return a.profiles.Create(name)
+}
+
+// SwitchProfile closes the current collection and opens another one.
+func (a *App) SwitchProfile(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if name == a.profile {
+		return nil
+	}
+	if !a.profiles.Exists(name) {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	return a.openProfile(name)
+}
+
+// DeleteProfile permanently deletes a collection other than the active one.
+func (a *App) DeleteProfile(name string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if name == a.profile {
+		return fmt.Errorf("cannot delete the active profile")
+	}
+	return a.profiles.Delete(name)
+}
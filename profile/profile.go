@@ -0,0 +1,195 @@
+// Package profile manages separate collections, each with its own database
+// and config under <root>/profiles/<name>/.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/dfirebaugh/mdsrs/config"
+)
+
+const (
+	DefaultRoot    = ".mdsrs"
+	DefaultProfile = "default"
+
+	configFile = "config.json"
+	dbFile     = "mdsrs.db"
+	stateFile  = "profiles.json"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+type Manager struct {
+	root string
+}
+
+type state struct {
+	Active string `json:"active"`
+}
+
+func NewManager(root string) *Manager {
+	return &Manager{root: root}
+}
+
+// Dir returns the directory holding a profile's files.
+func (m *Manager) Dir(name string) string {
+	return filepath.Join(m.root, "profiles", name)
+}
+
+func (m *Manager) ConfigPath(name string) string {
+	return filepath.Join(m.Dir(name), configFile)
+}
+
+// Init prepares the profiles directory. The first time it runs, a collection
+// from before profiles existed is moved into the default profile.
+func (m *Manager) Init() error {
+	if _, err := os.Stat(filepath.Join(m.root, "profiles")); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := m.Create(DefaultProfile); err != nil {
+		return err
+	}
+
+	return m.migrateLegacy()
+}
+
+// migrateLegacy moves <root>/config.json, and the database it points to if
+// it lives under root, into the default profile.
+func (m *Manager) migrateLegacy() error {
+	legacyConfig := filepath.Join(m.root, configFile)
+	if _, err := os.Stat(legacyConfig); os.IsNotExist(err) {
+		return nil
+	}
+
+	cfg, err := config.LoadConfigFromFile(legacyConfig)
+	if err != nil {
+		return fmt.Errorf("failed to read config to migrate: %w", err)
+	}
+
+	if rel, err := filepath.Rel(m.root, cfg.DBFile); err == nil && filepath.IsLocal(rel) {
+		target := filepath.Join(m.Dir(DefaultProfile), dbFile)
+		if err := os.Rename(cfg.DBFile, target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move database into default profile: %w", err)
+		}
+		cfg.DBFile = target
+	}
+
+	if err := cfg.SaveConfig(m.ConfigPath(DefaultProfile)); err != nil {
+		return fmt.Errorf("failed to move config into default profile: %w", err)
+	}
+	return os.Remove(legacyConfig)
+}
+
+// List returns the names of every profile, sorted.
+func (m *Manager) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.root, "profiles"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Exists reports whether a profile exists. Names that aren't valid profile
+// names, like "..", never exist, so that a name can't reach outside the
+// profiles directory once it's been checked.
+func (m *Manager) Exists(name string) bool {
+	if !validName.MatchString(name) {
+		return false
+	}
+	info, err := os.Stat(m.Dir(name))
+	return err == nil && info.IsDir()
+}
+
+// Create makes a new profile with a default config pointing at its own
+// database.
+func (m *Manager) Create(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if m.Exists(name) {
+		return fmt.Errorf("profile already exists: %s", name)
+	}
+
+	if err := os.MkdirAll(m.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	cfg := config.NewConfig()
+	cfg.DBFile = filepath.Join(m.Dir(name), dbFile)
+	return cfg.SaveConfig(m.ConfigPath(name))
+}
+
+// Delete removes a profile and all of its files. The active profile cannot
+// be deleted.
+func (m *Manager) Delete(name string) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+	if name == m.Active() {
+		return fmt.Errorf("cannot delete the active profile")
+	}
+	return os.RemoveAll(m.Dir(name))
+}
+
+// LoadConfig reads a profile's config, recreating it if it went missing.
+func (m *Manager) LoadConfig(name string) (*config.Config, error) {
+	if !m.Exists(name) {
+		return nil, fmt.Errorf("profile not found: %s", name)
+	}
+
+	path := m.ConfigPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		cfg := config.NewConfig()
+		cfg.DBFile = filepath.Join(m.Dir(name), dbFile)
+		if err := cfg.SaveConfig(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return config.LoadConfigFromFile(path)
+}
+
+// Active returns the profile used on startup, falling back to the default.
+func (m *Manager) Active() string {
+	data, err := os.ReadFile(filepath.Join(m.root, stateFile))
+	if err != nil {
+		return DefaultProfile
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil || s.Active == "" || !m.Exists(s.Active) {
+		return DefaultProfile
+	}
+	return s.Active
+}
+
+func (m *Manager) SetActive(name string) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	data, err := json.MarshalIndent(state{Active: name}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.root, stateFile), data, 0644)
+}
@@ -0,0 +1,129 @@
+package query
+
+import (
+	"strings"
+)
+
+type node interface {
+	sql(now int64, args *[]any) string
+}
+
+type andNode []node
+
+func (n andNode) sql(now int64, args *[]any) string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.sql(now, args)
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+type orNode []node
+
+func (n orNode) sql(now int64, args *[]any) string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.sql(now, args)
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+type notNode struct {
+	child node
+}
+
+func (n notNode) sql(now int64, args *[]any) string {
+	// COALESCE keeps NULL comparisons (e.g. no srs_data yet) from making
+	// both a term and its negation false.
+	return "NOT COALESCE(" + n.child.sql(now, args) + ", 0)"
+}
+
+// nowArg is a placeholder argument replaced with the query time.
+type nowArg struct{}
+
+type sqlTerm struct {
+	cond string
+	args []any
+}
+
+func (t sqlTerm) sql(now int64, args *[]any) string {
+	for _, arg := range t.args {
+		if _, ok := arg.(nowArg); ok {
+			arg = now
+		}
+		*args = append(*args, arg)
+	}
+	return "(" + t.cond + ")"
+}
+
+// textTerm matches free text in the card title or content.
+type textTerm struct {
+	value string
+}
+
+func (t textTerm) sql(now int64, args *[]any) string {
+	pattern := likePattern("*" + t.value + "*")
+	*args = append(*args, pattern, pattern)
+	return `(c.title LIKE ? ESCAPE '\' OR c.content LIKE ? ESCAPE '\')`
+}
+
+// matchTerm matches a column against a value where * is a wildcard.
+type matchTerm struct {
+	column string
+	value  string
+}
+
+func (t matchTerm) sql(now int64, args *[]any) string {
+	*args = append(*args, likePattern(t.value))
+	return "(" + t.column + ` LIKE ? ESCAPE '\')`
+}
+
+// tagTerm matches cards with a tag or one of its children (a/b is a child
+// of a).
+type tagTerm struct {
+	tag string
+}
+
+func (t tagTerm) sql(now int64, args *[]any) string {
+	*args = append(*args, likePattern(t.tag), likePattern(t.tag+"/*"))
+	return `(EXISTS (SELECT 1 FROM card_tags t WHERE t.card_id = c.id AND (t.tag LIKE ? ESCAPE '\' OR t.tag LIKE ? ESCAPE '\')))`
+}
+
+// fieldTerm matches cards whose note has a field containing value.
+type fieldTerm struct {
+	field string
+	value string
+}
+
+func (t fieldTerm) sql(now int64, args *[]any) string {
+	*args = append(*args, t.field, likePattern("*"+t.value+"*"))
+	return `(EXISTS (SELECT 1 FROM notes n, json_each(n.fields) f WHERE n.id = c.note_id AND f.key = ? COLLATE NOCASE AND f.value LIKE ? ESCAPE '\'))`
+}
+
+// sinceTerm matches cards whose timestamp column is within the last days.
+type sinceTerm struct {
+	column string
+	days   int
+}
+
+func (t sinceTerm) sql(now int64, args *[]any) string {
+	*args = append(*args, now-int64(t.days)*secondsPerDay)
+	return "(" + t.column + " >= ?)"
+}
+
+// likePattern converts a search value with * wildcards into a LIKE pattern.
+func likePattern(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\', '%', '_':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '*':
+			b.WriteRune('%')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,374 @@
+// Package query compiles Anki-style search expressions such as
+//
+//	deck:Go tag:concurrency is:due -is:suspended prop:reviews>5 added:7
+//
+// into parameterized SQL filters.
+//
+// A key that isn't one of the search keywords searches the note field of
+// that name, so Source:wikipedia finds notes citing Wikipedia.
+//
+// The generated WHERE clause expects the cards table to be aliased as c and
+// srs_data to be LEFT JOINed as s:
+//
+//	FROM cards c LEFT JOIN srs_data s ON s.card_id = c.id
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+const secondsPerDay = 86400
+
+// Query is a parsed search expression.
+type Query struct {
+	root node
+}
+
+// Parse parses a search expression. An empty expression matches every card.
+//
+// Terms are separated by whitespace and are implicitly ANDed together. "or"
+// joins alternatives, a leading "-" negates a term or a parenthesized group,
+// and values containing spaces can be double-quoted (deck:"My Deck").
+func Parse(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+
+	return &Query{root: root}, nil
+}
+
+// Where returns the SQL condition for the query and its arguments. now is the
+// unix time used for relative terms like is:due and added:N.
+func (q *Query) Where(now int64) (string, []any) {
+	if q == nil || q.root == nil {
+		return "1 = 1", nil
+	}
+
+	var args []any
+	sql := q.root.sql(now, &args)
+	return sql, args
+}
+
+// sortColumns maps the fields search results can be sorted by to SQL.
+var sortColumns = map[string]string{
+	"deck":    "c.deck_id COLLATE NOCASE",
+	"title":   "c.title COLLATE NOCASE",
+	"created": "c.created_at",
+	"updated": "c.updated_at",
+	"due":     "s.next_review",
+	"reviews": "COALESCE(s.review_count, 0)",
+	"ease":    "COALESCE(s.ease_factor, 1.0)",
+}
+
+// OrderBy returns the ORDER BY expression sorting results by field, which is
+// one of deck, title, created, updated, due, reviews or ease. An empty field
+// sorts by deck and title.
+func OrderBy(field string, descending bool) (string, error) {
+	if field == "" {
+		field = "deck"
+	}
+
+	column, ok := sortColumns[strings.ToLower(field)]
+	if !ok {
+		return "", fmt.Errorf("cannot sort by %q", field)
+	}
+
+	direction := " ASC"
+	if descending {
+		direction = " DESC"
+	}
+	return column + direction + ", c.title COLLATE NOCASE, c.id", nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenNot
+	tokenOr
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// quoted is set when any part of a word was quoted, so that a quoted
+	// "or" is searched for instead of being treated as an operator.
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] != ' ':
+			tokens = append(tokens, token{kind: tokenNot, text: "-"})
+			i++
+		default:
+			var b strings.Builder
+			inQuote, quoted := false, false
+			for ; i < len(runes); i++ {
+				r = runes[i]
+				if r == '"' {
+					inQuote = !inQuote
+					quoted = true
+					continue
+				}
+				if !inQuote && (r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '(' || r == ')') {
+					break
+				}
+				b.WriteRune(r)
+			}
+			if inQuote {
+				return nil, fmt.Errorf("unterminated quote in %q", input)
+			}
+
+			word := b.String()
+			switch {
+			case !quoted && strings.EqualFold(word, "or"):
+				tokens = append(tokens, token{kind: tokenOr, text: word})
+			case !quoted && strings.EqualFold(word, "and"):
+				// AND is implied between terms.
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word, quoted: quoted})
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []node{left}
+	for !p.done() && p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return orNode(nodes), nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var nodes []node
+	for !p.done() {
+		kind := p.peek().kind
+		if kind == tokenOr || kind == tokenRParen {
+			break
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	switch len(nodes) {
+	case 0:
+		if p.done() {
+			return nil, fmt.Errorf("expected a search term at end of query")
+		}
+		return nil, fmt.Errorf("expected a search term before %q", p.peek().text)
+	case 1:
+		return nodes[0], nil
+	}
+	return andNode(nodes), nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNot:
+		if p.done() {
+			return nil, fmt.Errorf("expected a search term after \"-\"")
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case tokenLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.peek().kind != tokenRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.next()
+		return n, nil
+	case tokenWord:
+		return parseTerm(t.text)
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+func parseTerm(word string) (node, error) {
+	key, value, ok := strings.Cut(word, ":")
+	if !ok {
+		return textTerm{value: word}, nil
+	}
+
+	switch strings.ToLower(key) {
+	case "deck":
+		return matchTerm{column: "c.deck_id", value: value}, nil
+	case "title":
+		return matchTerm{column: "c.title", value: "*" + value + "*"}, nil
+	case "tag":
+		return tagTerm{tag: value}, nil
+	case "is":
+		return parseState(value)
+	case "prop":
+		return parseProp(value)
+	case "flag":
+		return parseFlag(value)
+	case "added", "edited":
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("%s: expects a positive number of days, got %q", key, value)
+		}
+		column := "c.created_at"
+		if strings.ToLower(key) == "edited" {
+			column = "c.updated_at"
+		}
+		return sinceTerm{column: column, days: days}, nil
+	}
+
+	// Not a known key: search a note field by that name, or for the literal
+	// text, colon included.
+	return orNode{fieldTerm{field: key, value: value}, textTerm{value: word}}, nil
+}
+
+func parseState(value string) (node, error) {
+	switch strings.ToLower(value) {
+	case "due":
+		return sqlTerm{"s.next_review IS NOT NULL AND s.next_review <= ?", []any{nowArg{}}}, nil
+	case "new":
+		return sqlTerm{"s.card_id IS NULL", nil}, nil
+	case "review":
+		return sqlTerm{"s.card_id IS NOT NULL", nil}, nil
+	case "suspended":
+		return sqlTerm{"c.suspended = 1", nil}, nil
+	case "marked":
+		return sqlTerm{"c.marked = 1", nil}, nil
+	case "flagged":
+		return sqlTerm{"c.flag != 0", nil}, nil
+	}
+	return nil, fmt.Errorf("unknown state is:%s", value)
+}
+
+// parseFlag accepts a flag's number or name, so flag:1 and flag:red are the
+// same. flag:0 and flag:none match unflagged cards.
+func parseFlag(value string) (node, error) {
+	flag, err := models.ParseFlag(value)
+	if err != nil {
+		return nil, fmt.Errorf("unknown flag flag:%s", value)
+	}
+	return sqlTerm{"c.flag = ?", []any{int(flag)}}, nil
+}
+
+// propColumns maps prop: names to SQL expressions. due and ivl are in days.
+var propColumns = map[string]string{
+	"reviews": "COALESCE(s.review_count, 0)",
+	"ease":    "COALESCE(s.ease_factor, 1.0)",
+	"due":     "((s.next_review - ?) / " + strconv.Itoa(secondsPerDay) + ")",
+	"ivl":     "((s.next_review - s.last_review) / " + strconv.Itoa(secondsPerDay) + ")",
+}
+
+func parseProp(value string) (node, error) {
+	i := strings.IndexAny(value, "<>=!")
+	if i <= 0 {
+		return nil, fmt.Errorf("prop: expects a comparison like prop:ease<2, got %q", value)
+	}
+	name := strings.ToLower(value[:i])
+	rest := value[i:]
+
+	column, ok := propColumns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown property prop:%s", name)
+	}
+
+	var op string
+	for _, candidate := range []string{"<=", ">=", "!=", "<", ">", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("invalid comparison in prop:%s", value)
+	}
+
+	number, err := strconv.ParseFloat(rest[len(op):], 64)
+	if err != nil {
+		return nil, fmt.Errorf("prop:%s expects a number, got %q", name, rest[len(op):])
+	}
+
+	var args []any
+	if strings.Contains(column, "?") {
+		args = append(args, nowArg{})
+	}
+	args = append(args, number)
+
+	return sqlTerm{column + " " + op + " ?", args}, nil
+}
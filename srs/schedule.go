@@ -0,0 +1,132 @@
+package srs
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// ResetCard forgets a card's scheduling so that it is studied as new again.
+// Its past reviews stay in the review log if keepLog is set.
+func (s *SRS) ResetCard(cardID string, keepLog bool) error {
+	n, err := s.ResetCards([]string{cardID}, keepLog)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("card not found: %s", cardID)
+	}
+	return nil
+}
+
+// ResetCards resets cards like ResetCard in a single transaction. It returns
+// the number of cards reset.
+func (s *SRS) ResetCards(cardIDs []string, keepLog bool) (int, error) {
+	tx, err := s.database.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, id := range cardIDs {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM cards WHERE id = ? AND deleted_at IS NULL)`, id).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find card %s: %w", id, err)
+		}
+		if !exists {
+			continue
+		}
+
+		if _, err := tx.Exec(`DELETE FROM srs_data WHERE card_id = ?`, id); err != nil {
+			return 0, fmt.Errorf("failed to reset card %s: %w", id, err)
+		}
+		if !keepLog {
+			if _, err := tx.Exec(`DELETE FROM review_log WHERE card_id = ?`, id); err != nil {
+				return 0, fmt.Errorf("failed to clear review log of card %s: %w", id, err)
+			}
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit reset: %w", err)
+	}
+	return count, nil
+}
+
+// SetDueDate makes a card due at a random time between from and to (Unix
+// seconds, inclusive), keeping the rest of its history. Pass the same time
+// twice for a fixed date.
+func (s *SRS) SetDueDate(cardID string, from, to int64) error {
+	n, err := s.SetDueDates([]string{cardID}, from, to)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("card not found: %s", cardID)
+	}
+	return nil
+}
+
+// SetDueDates reschedules cards like SetDueDate in a single transaction,
+// picking a due time for each card separately. It returns the number of
+// cards rescheduled.
+func (s *SRS) SetDueDates(cardIDs []string, from, to int64) (int, error) {
+	if to < from {
+		return 0, fmt.Errorf("invalid date range: end is before start")
+	}
+
+	tx, err := s.database.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, id := range cardIDs {
+		due := from + rand.Int64N(to-from+1)
+		result, err := tx.Exec(`
+			INSERT INTO srs_data (card_id, last_review, next_review, review_count, ease_factor)
+			SELECT id, 0, ?, 0, 1.0 FROM cards WHERE id = ? AND deleted_at IS NULL
+			ON CONFLICT(card_id) DO UPDATE SET next_review = excluded.next_review
+		`, due, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reschedule card %s: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			count++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit reschedule: %w", err)
+	}
+	return count, nil
+}
+
+// GetReviewLog returns a card's past reviews, oldest first.
+func (s *SRS) GetReviewLog(cardID string) ([]models.Review, error) {
+	rows, err := s.database.Query(`
+		SELECT id, card_id, reviewed_at, outcome, next_review, ease_factor
+		FROM review_log
+		WHERE card_id = ?
+		ORDER BY reviewed_at, id
+	`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review log: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		var r models.Review
+		if err := rows.Scan(&r.ID, &r.CardID, &r.ReviewedAt, &r.Outcome, &r.NextReview, &r.EaseFactor); err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
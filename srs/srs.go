@@ -2,18 +2,27 @@ package srs
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/query"
+	"github.com/dfirebaugh/mdsrs/store"
 	"github.com/sirupsen/logrus"
 )
 
+// newCardOrder orders new cards within a review by their deck's setting. It
+// expects decks to be joined as d.
+const newCardOrder = `CASE WHEN s.next_review IS NULL THEN
+				CASE d.new_card_order WHEN 'random' THEN RANDOM() ELSE c.position END
+			END`
+
 type SRS struct {
 	database *sql.DB
 }
 
-func NewSRS(deckName string, database *sql.DB) models.SRS {
-	return &SRS{database}
+func NewSRS(deckName string, st *store.Store) models.SRS {
+	return &SRS{st.DB()}
 }
 
 func (s *SRS) UpdateSRSData(cardID string, outcome models.ReviewConfidence) {
@@ -42,6 +51,14 @@ func (s *SRS) UpdateSRSData(cardID string, outcome models.ReviewConfidence) {
 		cardID, data.LastReview, data.NextReview, data.ReviewCount, data.EaseFactor)
 
 	s.UpdateCardData(cardID, data)
+
+	_, err := s.database.Exec(`
+		INSERT INTO review_log (card_id, reviewed_at, outcome, next_review, ease_factor)
+		VALUES (?, ?, ?, ?, ?)
+	`, cardID, now, outcome, data.NextReview, data.EaseFactor)
+	if err != nil {
+		logrus.Errorf("Failed to log review: %v", err)
+	}
 }
 
 func (s *SRS) GetReviewCards(numCards int) []models.Flashcard {
@@ -50,13 +67,15 @@ func (s *SRS) GetReviewCards(numCards int) []models.Flashcard {
 		SELECT c.id, c.deck_id, c.title, c.content
 		FROM cards c
 		LEFT JOIN srs_data s ON c.id = s.card_id
-		WHERE s.next_review IS NULL OR s.next_review <= ?
+		LEFT JOIN decks d ON c.deck_id = d.name
+		WHERE c.suspended = 0 AND c.deleted_at IS NULL AND (s.next_review IS NULL OR s.next_review <= ?)
 		ORDER BY 
 			CASE 
 				WHEN s.next_review IS NULL THEN 0   -- New cards first
 				WHEN s.next_review < ? THEN 1       -- Overdue cards second
 				ELSE 2                              -- Due cards third
 			END,
+			`+newCardOrder+`,                    -- New cards in deck order
 			s.next_review ASC,
 			s.review_count ASC                          -- Lower review count first
 		LIMIT ?
@@ -107,13 +126,15 @@ func (s *SRS) GetReviewCardsForDecks(deckNames []string, numCards int) []models.
 		SELECT c.id, c.deck_id, c.title, c.content
 		FROM cards c
 		LEFT JOIN srs_data s ON c.id = s.card_id
-		WHERE c.deck_id IN (` + placeholders + `) AND (s.next_review IS NULL OR s.next_review <= ?)
+		LEFT JOIN decks d ON c.deck_id = d.name
+		WHERE c.deck_id IN (` + placeholders + `) AND c.suspended = 0 AND c.deleted_at IS NULL AND (s.next_review IS NULL OR s.next_review <= ?)
 		ORDER BY 
 			CASE 
 				WHEN s.next_review IS NULL THEN 0
 				WHEN s.next_review < ? THEN 1
 				ELSE 2
 			END,
+			` + newCardOrder + `,
 			s.next_review ASC,
 			s.review_count ASC
 		LIMIT ?
@@ -140,6 +161,53 @@ func (s *SRS) GetReviewCardsForDecks(deckNames []string, numCards int) []models.
 	return cards
 }
 
+// GetCustomStudyCards gets unsuspended cards matching a search expression,
+// regardless of whether they are due, ordered like a regular review.
+func (s *SRS) GetCustomStudyCards(expr string, numCards int) ([]models.Flashcard, error) {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search %q: %w", expr, err)
+	}
+
+	now := time.Now().Unix()
+	where, args := q.Where(now)
+	args = append(args, now, numCards)
+
+	rows, err := s.database.Query(`
+		SELECT c.id, c.deck_id, c.title, c.content
+		FROM cards c
+		LEFT JOIN srs_data s ON c.id = s.card_id
+		LEFT JOIN decks d ON c.deck_id = d.name
+		WHERE c.suspended = 0 AND c.deleted_at IS NULL AND `+where+`
+		ORDER BY
+			CASE
+				WHEN s.next_review IS NULL THEN 0
+				WHEN s.next_review <= ? THEN 1
+				ELSE 2
+			END,
+			`+newCardOrder+`,
+			s.next_review ASC,
+			s.review_count ASC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom study cards: %w", err)
+	}
+	defer rows.Close()
+
+	cards := []models.Flashcard{}
+	for rows.Next() {
+		var card models.Flashcard
+		if err := rows.Scan(&card.ID, &card.DeckID, &card.Title, &card.Content); err != nil {
+			logrus.Errorf("Failed to scan card: %v", err)
+			continue
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
 func (s *SRS) GetCardData(cardID string) models.CardData {
 	var data models.CardData
 	err := s.database.QueryRow(`
@@ -205,7 +273,7 @@ SELECT c.id, c.deck_id, c.title, c.content, s.next_review, s.review_count, s.eas
 FROM cards c
 INNER JOIN srs_data s ON c.id = s.card_id
 LEFT JOIN decks d ON c.deck_id = d.name
-WHERE s.next_review > (SELECT strftime('%s', 'now'))
+WHERE s.next_review > (SELECT strftime('%s', 'now')) AND c.suspended = 0 AND c.deleted_at IS NULL
 ORDER BY s.next_review ASC;
 	`, now)
 	if err != nil {
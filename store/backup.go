@@ -0,0 +1,303 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	backupPrefix     = "mdsrs-"
+	backupExt        = ".db"
+	backupTimeFormat = "20060102-150405"
+	// replacedExt is added to the name of a database replaced by a restore
+	// until the restore is finished.
+	replacedExt = ".replaced"
+)
+
+type BackupOptions struct {
+	// Dir is where backups are written.
+	Dir string
+	// Keep is the number of backups kept; older ones are deleted.
+	Keep int
+	// Interval between backups while the store is open. Zero disables
+	// periodic backups.
+	Interval time.Duration
+}
+
+type backupScheduler struct {
+	opts BackupOptions
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// BackupDir returns the default backup directory for a database file.
+func BackupDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// StartBackups backs the database up now, then every opts.Interval, and
+// once more when the store is closed.
+func (s *Store) StartBackups(opts BackupOptions) error {
+	if s.path == MemoryPath {
+		return fmt.Errorf("in-memory databases cannot be backed up")
+	}
+	if opts.Keep <= 0 {
+		return fmt.Errorf("backup count must be positive")
+	}
+	if s.backups != nil {
+		return fmt.Errorf("backups already started")
+	}
+
+	if _, err := s.Backup(opts.Dir, opts.Keep); err != nil {
+		return err
+	}
+
+	b := &backupScheduler{opts: opts, stop: make(chan struct{})}
+	s.backups = b
+
+	if opts.Interval > 0 {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			ticker := time.NewTicker(opts.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if _, err := s.Backup(opts.Dir, opts.Keep); err != nil {
+						logrus.Errorf("Scheduled backup failed: %v", err)
+					}
+				case <-b.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+// stopBackups stops periodic backups and takes a final one.
+func (s *Store) stopBackups() {
+	b := s.backups
+	if b == nil {
+		return
+	}
+	s.backups = nil
+
+	close(b.stop)
+	b.wg.Wait()
+
+	if _, err := s.Backup(b.opts.Dir, b.opts.Keep); err != nil {
+		logrus.Errorf("Backup on close failed: %v", err)
+	}
+}
+
+// Backup writes a consistent snapshot of the database into dir and deletes
+// all but the newest keep backups. If nothing changed since the newest
+// backup, no new file is kept and its path is returned instead.
+func (s *Store) Backup(dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupPrefix + time.Now().Format(backupTimeFormat)
+	target := filepath.Join(dir, name+backupExt)
+	for i := 1; fileExists(target); i++ {
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, backupExt))
+	}
+
+	if _, err := s.db.Exec(`VACUUM INTO ?`, target); err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return target, err
+	}
+	for _, backup := range backups {
+		if backup.Name == filepath.Base(target) {
+			continue
+		}
+		previous := filepath.Join(dir, backup.Name)
+		if same, _ := sameContents(target, previous); same {
+			if err := os.Remove(target); err != nil {
+				return target, fmt.Errorf("failed to remove unchanged backup: %w", err)
+			}
+			return previous, nil
+		}
+		break
+	}
+
+	return target, pruneBackups(dir, keep)
+}
+
+// ListBackups returns the backups in dir, newest first.
+func ListBackups(dir string) ([]models.Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.Backup{}, nil
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isBackupName(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, models.Backup{
+			Name:      name,
+			Size:      info.Size(),
+			CreatedAt: info.ModTime().Unix(),
+		})
+	}
+
+	// Names embed the time, so they sort chronologically once the extension
+	// is out of the way of same-second "-1" suffixes.
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i].Name, backupExt) > strings.TrimSuffix(backups[j].Name, backupExt)
+	})
+	return backups, nil
+}
+
+// StageBackup copies a backup next to the database at dbPath, so that it
+// can be restored even if the original is rotated away before then.
+func StageBackup(dir string, name string, dbPath string) (string, error) {
+	if !isBackupName(name) || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid backup name: %s", name)
+	}
+
+	source := filepath.Join(dir, name)
+	if !fileExists(source) {
+		return "", fmt.Errorf("backup not found: %s", name)
+	}
+
+	staged := dbPath + ".restore"
+	if err := copyFile(source, staged); err != nil {
+		os.Remove(staged)
+		return "", fmt.Errorf("failed to copy backup: %w", err)
+	}
+	return staged, nil
+}
+
+// RestoreBackup replaces the database file at dbPath with a backup staged by
+// StageBackup. The database must not be open. The replaced database is kept
+// until FinishRestore deletes it or UndoRestore puts it back.
+func RestoreBackup(staged string, dbPath string) error {
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbPath + suffix)
+	}
+	if err := os.Rename(dbPath, dbPath+replacedExt); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to move database aside: %w", err)
+	}
+	if err := os.Rename(staged, dbPath); err != nil {
+		if undoErr := UndoRestore(dbPath); undoErr != nil {
+			logrus.Errorf("Failed to undo restore: %v", undoErr)
+		}
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+// UndoRestore puts back the database RestoreBackup replaced. The database
+// must not be open.
+func UndoRestore(dbPath string) error {
+	if err := os.Rename(dbPath+replacedExt, dbPath); err != nil {
+		return fmt.Errorf("failed to put back database: %w", err)
+	}
+	return nil
+}
+
+// FinishRestore deletes the database RestoreBackup replaced.
+func FinishRestore(dbPath string) error {
+	if err := os.Remove(dbPath + replacedExt); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete replaced database: %w", err)
+	}
+	return nil
+}
+
+func pruneBackups(dir string, keep int) error {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(dir, backups[i].Name)); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupExt)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sameContents(a, b string) (bool, error) {
+	hash := func(path string) ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+
+	ha, err := hash(a)
+	if err != nil {
+		return false, err
+	}
+	hb, err := hash(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ha, hb), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
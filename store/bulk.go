@@ -0,0 +1,236 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/query"
+)
+
+// SelectCards returns the IDs of the cards a selection refers to. Listed IDs
+// are returned as given, less duplicates; bulk operations skip those that
+// don't exist.
+func (s *Store) SelectCards(sel models.CardSelection) ([]string, error) {
+	if sel.Query == "" {
+		if len(sel.CardIDs) == 0 {
+			return nil, fmt.Errorf("no cards selected")
+		}
+		ids := []string{}
+		seen := make(map[string]bool)
+		for _, id := range sel.CardIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	q, err := query.Parse(sel.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search %q: %w", sel.Query, err)
+	}
+	where, args := q.Where(time.Now().Unix())
+
+	rows, err := s.db.Query(`
+		SELECT c.id
+		FROM cards c
+		LEFT JOIN srs_data s ON s.card_id = c.id
+		WHERE c.deleted_at IS NULL AND `+where+`
+		ORDER BY c.deck_id, c.position, c.rowid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select cards: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MoveCards moves cards to the end of another deck in the order given,
+// creating the deck if needed. A card generated from another, like a cloze
+// sibling or reverse card, can't be in a different deck from it, so moving
+// either moves the card and everything generated from it. It returns the
+// number of cards moved.
+func (s *Store) MoveCards(cardIDs []string, deckName string) (int, error) {
+	if deckName == "" {
+		return 0, fmt.Errorf("deck name cannot be empty")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		INSERT INTO decks (name, created_at, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET deleted_at = NULL, trash_batch = NULL
+	`, deckName, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create deck %s: %w", deckName, err)
+	}
+
+	count := 0
+	seen := make(map[string]bool)
+	for _, id := range cardIDs {
+		var sourceID string
+		err := tx.QueryRow(`
+			SELECT COALESCE(source_id, '') FROM cards WHERE id = ? AND deleted_at IS NULL
+		`, id).Scan(&sourceID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to find card %s: %w", id, err)
+		}
+		if sourceID != "" {
+			id = sourceID
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		group, err := cardGroup(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		for _, card := range group {
+			if card.deckID == deckName {
+				continue
+			}
+			// Each card is moved on its own so that it gets the next
+			// position.
+			_, err = tx.Exec(`
+				UPDATE cards SET deck_id = ?, position = `+nextPosition+`, updated_at = ?
+				WHERE id = ?
+			`, deckName, deckName, now, card.id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to move card %s: %w", card.id, err)
+			}
+			if err := touchDeck(tx, &Deck{Name: card.deckID}, now); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	if err := touchDeck(tx, &Deck{Name: deckName}, now); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit move: %w", err)
+	}
+	return count, nil
+}
+
+type groupCard struct {
+	id     string
+	deckID string
+}
+
+// cardGroup returns a card that isn't in the trash followed by the cards
+// generated from it, in deck order.
+func cardGroup(tx *sql.Tx, id string) ([]groupCard, error) {
+	rows, err := tx.Query(`
+		SELECT id, deck_id FROM cards
+		WHERE (id = ? OR source_id = ?) AND deleted_at IS NULL
+		ORDER BY id != ?, position, rowid
+	`, id, id, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load card %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	var group []groupCard
+	for rows.Next() {
+		var card groupCard
+		if err := rows.Scan(&card.id, &card.deckID); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		group = append(group, card)
+	}
+	return group, rows.Err()
+}
+
+// SetCardsSuspended suspends or unsuspends cards. It returns the number of
+// cards changed.
+func (s *Store) SetCardsSuspended(cardIDs []string, suspended bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, id := range cardIDs {
+		result, err := tx.Exec(`
+			UPDATE cards SET suspended = ?
+			WHERE id = ? AND deleted_at IS NULL AND suspended != ?
+		`, suspended, id, suspended)
+		if err != nil {
+			return 0, fmt.Errorf("failed to suspend card %s: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			count++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit suspend: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteCards moves cards to the trash. It returns the number of cards
+// deleted.
+func (s *Store) DeleteCards(cardIDs []string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	count := 0
+	for _, id := range cardIDs {
+		var deckName string
+		err := tx.QueryRow(`SELECT deck_id FROM cards WHERE id = ? AND deleted_at IS NULL`, id).Scan(&deckName)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to find card %s: %w", id, err)
+		}
+		batch := GenerateID()
+		if _, err := tx.Exec(`UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE id = ?`, now, batch, id); err != nil {
+			return 0, fmt.Errorf("failed to delete card %s: %w", id, err)
+		}
+		if err := trashGeneratedCards(tx, id, now, batch); err != nil {
+			return 0, err
+		}
+		if err := touchDeck(tx, &Deck{Name: deckName}, now); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit delete: %w", err)
+	}
+	return count, nil
+}
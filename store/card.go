@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"slices"
+	"time"
 
 	"github.com/dfirebaugh/mdsrs/models"
 	"github.com/google/uuid"
@@ -11,18 +12,25 @@ import (
 
 type Flashcard = models.Flashcard
 
+// nextPosition is an SQL expression for the position after the last card in
+// the deck given as its argument.
+const nextPosition = `(SELECT COALESCE(MAX(position), -1) + 1 FROM cards WHERE deck_id = ?)`
+
 func GenerateID() string {
 	// TODO: should probably move this to the db now
 	return uuid.New().String()
 }
 
-func FindCardByID(deck *Deck, cardID string) *models.Flashcard {
+func (s *Store) FindCardByID(deck *Deck, cardID string) *models.Flashcard {
 	var card models.Flashcard
-	err := db.QueryRow(`
-		SELECT id, deck_id, title, content
+	err := s.db.QueryRow(`
+		SELECT id, deck_id, title, content, suspended, flag, marked, COALESCE(note_id, ''), ord,
+			COALESCE(source_id, ''), cloze, reverse, source_url, created_at, updated_at, position
 		FROM cards
-		WHERE id = ? AND deck_id = ?
-	`, cardID, deck.Name).Scan(&card.ID, &card.DeckID, &card.Title, &card.Content)
+		WHERE id = ? AND deck_id = ? AND deleted_at IS NULL
+	`, cardID, deck.Name).Scan(&card.ID, &card.DeckID, &card.Title, &card.Content, &card.Suspended,
+		&card.Flag, &card.Marked, &card.NoteID, &card.Ord, &card.SourceID, &card.Cloze, &card.Reverse, &card.SourceURL,
+		&card.CreatedAt, &card.UpdatedAt, &card.Position)
 
 	if err == sql.ErrNoRows {
 		return nil
@@ -34,51 +42,165 @@ func FindCardByID(deck *Deck, cardID string) *models.Flashcard {
 	return &card
 }
 
-func AddCard(deck *Deck, card models.Flashcard) error {
+func (s *Store) AddCard(deck *Deck, card models.Flashcard) error {
 	if card.ID == "" {
 		card.ID = GenerateID()
 	}
-	_, err := db.Exec(`
-		INSERT INTO cards (id, deck_id, title, content)
-		VALUES (?, ?, ?, ?)
-	`, card.ID, deck.Name, card.Title, card.Content)
+	fm, err := cardFrontMatter(&card)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		INSERT INTO cards (id, deck_id, title, content, created_at, updated_at, position, source_url)
+		VALUES (?, ?, ?, ?, ?, ?, `+nextPosition+`, ?)
+	`, card.ID, deck.Name, card.Title, card.Content, now, now, deck.Name, card.SourceURL)
 	if err != nil {
 		return fmt.Errorf("failed to add card: %w", err)
 	}
+	if err := applyFrontMatter(tx, card.ID, fm); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`SELECT position, suspended, flag, marked FROM cards WHERE id = ?`, card.ID).
+		Scan(&card.Position, &card.Suspended, &card.Flag, &card.Marked)
+	if err != nil {
+		return fmt.Errorf("failed to read card position: %w", err)
+	}
+	card.CreatedAt, card.UpdatedAt = now, now
+	if err := touchDeck(tx, deck, now); err != nil {
+		return err
+	}
+	if card.Tags, err = saveCardTags(tx, card); err != nil {
+		return err
+	}
+	cloze, generated, err := syncGeneratedCards(tx, deck, card, now)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit card: %w", err)
+	}
+	card.Cloze = cloze
 	deck.Cards = append(deck.Cards, card)
+	if generated {
+		return s.reloadCards(deck)
+	}
 	return nil
 }
 
-func DeleteCard(deck *Deck, cardID string) error {
-	_, err := db.Exec(`DELETE FROM cards WHERE id = ? AND deck_id = ?`, cardID, deck.Name)
+// DeleteCard moves a card, and any cards generated from it, to the trash.
+// Their SRS data, tags and revisions are kept until the trash is purged.
+func (s *Store) DeleteCard(deck *Deck, cardID string) error {
+	now := time.Now().Unix()
+	batch := GenerateID()
+	result, err := s.db.Exec(`
+		UPDATE cards SET deleted_at = ?, trash_batch = ?
+		WHERE id = ? AND deck_id = ? AND deleted_at IS NULL
+	`, now, batch, cardID, deck.Name)
 	if err != nil {
 		return fmt.Errorf("failed to delete card: %w", err)
 	}
-	for i, c := range deck.Cards {
-		if c.ID == cardID {
-			deck.Cards = slices.Delete(deck.Cards, i, i+1)
-			break
+	if n, _ := result.RowsAffected(); n > 0 {
+		if err := trashGeneratedCards(s.db, cardID, now, batch); err != nil {
+			return err
 		}
 	}
+	if err := touchDeck(s.db, deck, now); err != nil {
+		return err
+	}
+	deck.Cards = slices.DeleteFunc(deck.Cards, func(c models.Flashcard) bool {
+		return c.ID == cardID || c.SourceID == cardID
+	})
 	return nil
 }
 
-func AddOrUpdateCard(deck *Deck, card models.Flashcard) error {
+// AddOrUpdateCard saves a card, keeping its previous version as a revision
+// and updating its tags and generated cards along with it. Either all of it
+// is saved or none of it is. Saving a card that is in the trash takes it out,
+// so that restoring what it was trashed with doesn't touch it again.
+func (s *Store) AddOrUpdateCard(deck *Deck, card models.Flashcard) error {
 	if card.ID == "" {
 		card.ID = GenerateID()
 	}
 
-	_, err := db.Exec(`
-		INSERT INTO cards (id, deck_id, title, content)
-		VALUES (?, ?, ?, ?)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sourceID string
+	err = tx.QueryRow(`SELECT COALESCE(source_id, '') FROM cards WHERE id = ?`, card.ID).Scan(&sourceID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to find card: %w", err)
+	}
+	if sourceID != "" {
+		return fmt.Errorf("card %s is generated from card %s; edit that card instead", card.ID, sourceID)
+	}
+	fm, err := cardFrontMatter(&card)
+	if err != nil {
+		return err
+	}
+
+	if err := snapshotCard(tx, card.ID, card.Title, card.Content); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		INSERT INTO cards (id, deck_id, title, content, created_at, updated_at, position, source_url)
+		VALUES (?, ?, ?, ?, ?, ?, `+nextPosition+`, ?)
 		ON CONFLICT(id) DO UPDATE SET
+			updated_at = CASE
+				WHEN title IS NOT excluded.title OR content IS NOT excluded.content THEN excluded.updated_at
+				ELSE updated_at
+			END,
 			title = excluded.title,
-			content = excluded.content
-	`, card.ID, deck.Name, card.Title, card.Content)
+			content = excluded.content,
+			source_url = excluded.source_url,
+			deleted_at = NULL,
+			trash_batch = NULL
+	`, card.ID, deck.Name, card.Title, card.Content, now, now, deck.Name, card.SourceURL)
 
 	if err != nil {
 		return fmt.Errorf("failed to add/update card: %w", err)
 	}
+	if err := applyFrontMatter(tx, card.ID, fm); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`
+		SELECT created_at, updated_at, position, suspended, flag, marked, COALESCE(note_id, ''), ord, cloze
+		FROM cards WHERE id = ?
+	`, card.ID).Scan(&card.CreatedAt, &card.UpdatedAt, &card.Position, &card.Suspended, &card.Flag, &card.Marked,
+		&card.NoteID, &card.Ord, &card.Cloze)
+	if err != nil {
+		return fmt.Errorf("failed to read card timestamps: %w", err)
+	}
+	if card.UpdatedAt == now {
+		if err := touchDeck(tx, deck, now); err != nil {
+			return err
+		}
+	}
+	if card.Tags, err = saveCardTags(tx, card); err != nil {
+		return err
+	}
+	cloze, generated, err := syncGeneratedCards(tx, deck, card, now)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit card: %w", err)
+	}
+	card.Cloze = cloze
 
 	found := false
 	for i, c := range deck.Cards {
@@ -92,5 +214,58 @@ func AddOrUpdateCard(deck *Deck, card models.Flashcard) error {
 		deck.Cards = append(deck.Cards, card)
 	}
 
+	if generated {
+		return s.reloadCards(deck)
+	}
+	return nil
+}
+
+func saveCardTags(tx *sql.Tx, card models.Flashcard) ([]string, error) {
+	if err := syncCardTags(tx, card.ID, card.Content); err != nil {
+		return nil, err
+	}
+	return cardTags(tx, card.ID)
+}
+
+// SetCardSuspended excludes a card from (or returns it to) review.
+func (s *Store) SetCardSuspended(deck *Deck, cardID string, suspended bool) error {
+	result, err := s.db.Exec(`UPDATE cards SET suspended = ? WHERE id = ? AND deck_id = ? AND deleted_at IS NULL`, suspended, cardID, deck.Name)
+	if err != nil {
+		return fmt.Errorf("failed to suspend card: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("card not found: %s", cardID)
+	}
+
+	for i, c := range deck.Cards {
+		if c.ID == cardID {
+			deck.Cards[i].Suspended = suspended
+			break
+		}
+	}
+	return nil
+}
+
+// SetCardFlag sets a card's flag. FlagNone clears it.
+func (s *Store) SetCardFlag(cardID string, flag models.Flag) error {
+	if !flag.Valid() {
+		return fmt.Errorf("invalid flag: %d", flag)
+	}
+	return s.setCardField(cardID, "flag", flag)
+}
+
+// SetCardMarked marks or unmarks a card.
+func (s *Store) SetCardMarked(cardID string, marked bool) error {
+	return s.setCardField(cardID, "marked", marked)
+}
+
+func (s *Store) setCardField(cardID string, column string, value any) error {
+	result, err := s.db.Exec(`UPDATE cards SET `+column+` = ? WHERE id = ? AND deleted_at IS NULL`, value, cardID)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", column, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("card not found: %s", cardID)
+	}
 	return nil
 }
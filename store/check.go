@@ -0,0 +1,219 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+const (
+	IssueOrphanedSRS      = "orphaned_srs"
+	IssueOrphanedTag      = "orphaned_tag"
+	IssueOrphanedRevision = "orphaned_revision"
+	IssueOrphanedReview   = "orphaned_review"
+	IssueDanglingDeck     = "dangling_deck"
+	IssueDanglingNote     = "dangling_note"
+	IssueDanglingSource   = "dangling_source"
+	IssueDuplicateID      = "duplicate_id"
+	IssueMissingID        = "missing_id"
+	IssueInvalidSRS       = "invalid_srs"
+
+	// RecoveredDeck receives cards that don't name a deck at all.
+	RecoveredDeck = "Recovered"
+)
+
+// consistencyChecks find rows that violate the schema's intent. Each query
+// returns an ID identifying the row and a detail message.
+var consistencyChecks = []struct {
+	kind, table, query string
+}{
+	{IssueOrphanedSRS, "srs_data", `
+		SELECT card_id, 'no card with this ID'
+		FROM srs_data WHERE card_id NOT IN (SELECT id FROM cards WHERE id IS NOT NULL)`},
+	{IssueOrphanedTag, "card_tags", `
+		SELECT card_id, 'tag ' || tag || ' on missing card'
+		FROM card_tags WHERE card_id NOT IN (SELECT id FROM cards WHERE id IS NOT NULL)`},
+	{IssueOrphanedRevision, "card_revisions", `
+		SELECT CAST(id AS TEXT), 'revision of missing card ' || card_id
+		FROM card_revisions WHERE card_id NOT IN (SELECT id FROM cards WHERE id IS NOT NULL)`},
+	{IssueOrphanedReview, "review_log", `
+		SELECT CAST(id AS TEXT), 'review of missing card ' || card_id
+		FROM review_log WHERE card_id NOT IN (SELECT id FROM cards WHERE id IS NOT NULL)`},
+	{IssueDanglingDeck, "cards", `
+		SELECT COALESCE(id, ''), 'deck ' || COALESCE(quote(deck_id), 'NULL') || ' does not exist'
+		FROM cards WHERE deck_id IS NULL OR deck_id NOT IN (SELECT name FROM decks)`},
+	{IssueDanglingNote, "cards", `
+		SELECT id, 'note ' || note_id || ' does not exist'
+		FROM cards WHERE note_id IS NOT NULL AND note_id NOT IN (SELECT id FROM notes)`},
+	{IssueDanglingSource, "cards", `
+		SELECT id, 'source card ' || source_id || ' does not exist'
+		FROM cards WHERE source_id IS NOT NULL AND source_id NOT IN (SELECT id FROM cards WHERE id IS NOT NULL)`},
+	{IssueDuplicateID, "cards", `
+		SELECT id, COUNT(*) || ' cards share this ID'
+		FROM cards WHERE id IS NOT NULL GROUP BY id HAVING COUNT(*) > 1`},
+	{IssueMissingID, "cards", `
+		SELECT CAST(rowid AS TEXT), 'card has no ID'
+		FROM cards WHERE id IS NULL OR id = ''`},
+	{IssueInvalidSRS, "srs_data", `
+		SELECT card_id,
+			CASE
+				WHEN review_count IS NULL OR review_count < 0 THEN 'invalid review count ' || COALESCE(review_count, 'NULL')
+				WHEN ease_factor IS NULL OR ease_factor <= 0 OR ease_factor > 1e308 THEN 'invalid ease factor ' || COALESCE(ease_factor, 'NULL')
+				WHEN last_review IS NULL THEN 'missing last review time'
+				ELSE 'missing next review time'
+			END
+		FROM srs_data
+		WHERE review_count IS NULL OR review_count < 0
+			OR ease_factor IS NULL OR ease_factor <= 0 OR ease_factor > 1e308
+			OR last_review IS NULL OR next_review IS NULL`},
+}
+
+// CheckDatabase runs SQLite's integrity check and looks for inconsistent
+// rows left behind by earlier versions. With repair set, the inconsistencies
+// are fixed in a single transaction; corruption reported by the integrity
+// check can only be fixed by restoring a backup.
+func (s *Store) CheckDatabase(repair bool) (*models.IntegrityReport, error) {
+	report := &models.IntegrityReport{
+		IntegrityCheck: []string{},
+		Issues:         []models.IntegrityIssue{},
+	}
+
+	rows, err := s.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		if line != "ok" {
+			report.IntegrityCheck = append(report.IntegrityCheck, line)
+		}
+	}
+	rows.Close()
+
+	for _, check := range consistencyChecks {
+		issues, err := findIssues(s.db, check.kind, check.table, check.query)
+		if err != nil {
+			return nil, err
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	report.OK = len(report.IntegrityCheck) == 0 && len(report.Issues) == 0
+	if !repair || len(report.Issues) == 0 {
+		return report, nil
+	}
+
+	if err := s.repair(); err != nil {
+		return report, fmt.Errorf("failed to repair database: %w", err)
+	}
+	report.Repaired = true
+
+	return report, nil
+}
+
+func findIssues(q queryer, kind, table, query string) ([]models.IntegrityIssue, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var issues []models.IntegrityIssue
+	for rows.Next() {
+		issue := models.IntegrityIssue{Kind: kind, Table: table}
+		var id, detail sql.NullString
+		if err := rows.Scan(&id, &detail); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", kind, err)
+		}
+		issue.ID = id.String
+		issue.Detail = detail.String
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (s *Store) repair() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Give every card a unique ID first so the orphan checks below see them.
+	if err := reassignCardIDs(tx); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE cards SET deck_id = ? WHERE deck_id IS NULL OR deck_id = ''`, []any{RecoveredDeck}},
+		{`
+			INSERT INTO decks (name, created_at, updated_at)
+			SELECT DISTINCT deck_id, ?, ? FROM cards WHERE deck_id NOT IN (SELECT name FROM decks)
+		`, []any{now, now}},
+		// Cards without their note are kept as plain markdown cards.
+		{`UPDATE cards SET note_id = NULL, ord = 0 WHERE note_id NOT IN (SELECT id FROM notes)`, nil},
+		// Generated cards without their source would never be updated again.
+		{`DELETE FROM cards WHERE source_id NOT IN (SELECT id FROM cards)`, nil},
+		{`DELETE FROM srs_data WHERE card_id NOT IN (SELECT id FROM cards)`, nil},
+		{`DELETE FROM card_tags WHERE card_id NOT IN (SELECT id FROM cards)`, nil},
+		{`DELETE FROM card_revisions WHERE card_id NOT IN (SELECT id FROM cards)`, nil},
+		{`DELETE FROM review_log WHERE card_id NOT IN (SELECT id FROM cards)`, nil},
+		{`UPDATE srs_data SET review_count = 0 WHERE review_count IS NULL OR review_count < 0`, nil},
+		{`UPDATE srs_data SET ease_factor = 1.0 WHERE ease_factor IS NULL OR ease_factor <= 0 OR ease_factor > 1e308`, nil},
+		{`UPDATE srs_data SET last_review = 0 WHERE last_review IS NULL`, nil},
+		{`UPDATE srs_data SET next_review = 0 WHERE next_review IS NULL`, nil},
+	}
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// reassignCardIDs gives cards without an ID, and all but the first of cards
+// sharing one, a new ID. Duplicates lose any SRS data, which can't be told
+// apart from the original's.
+func reassignCardIDs(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT rowid FROM cards c
+		WHERE id IS NULL OR id = ''
+			OR rowid > (SELECT MIN(rowid) FROM cards d WHERE d.id = c.id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	var rowids []int64
+	for rows.Next() {
+		var rowid int64
+		if err := rows.Scan(&rowid); err != nil {
+			rows.Close()
+			return err
+		}
+		rowids = append(rowids, rowid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rowid := range rowids {
+		if _, err := tx.Exec(`UPDATE cards SET id = ? WHERE rowid = ?`, GenerateID(), rowid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
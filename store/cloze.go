@@ -0,0 +1,92 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// syncClozeCards makes a card with cloze deletions ask for one of its cloze
+// numbers and generates a sibling card for each of the others. Siblings are
+// updated when the card is, moved to the trash when their cloze is removed
+// and restored when it comes back, so each keeps its own SRS data. It
+// returns the cloze number the card asks for and whether any sibling changed.
+func syncClozeCards(tx *sql.Tx, deck *Deck, card models.Flashcard, now int64) (int, bool, error) {
+	numbers := md.ClozeNumbers([]byte(card.Content))
+
+	type sibling struct {
+		id      string
+		deleted bool
+	}
+	siblings := make(map[int]sibling)
+	rows, err := tx.Query(`
+		SELECT id, cloze, deleted_at IS NOT NULL FROM cards WHERE source_id = ? AND cloze > 0
+	`, card.ID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load cloze cards: %w", err)
+	}
+	for rows.Next() {
+		var sib sibling
+		var n int
+		if err := rows.Scan(&sib.id, &n, &sib.deleted); err != nil {
+			rows.Close()
+			return 0, false, fmt.Errorf("failed to scan cloze card: %w", err)
+		}
+		siblings[n] = sib
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, false, fmt.Errorf("failed to load cloze cards: %w", err)
+	}
+
+	// The card keeps its cloze while it has it, so that its SRS data stays
+	// with the same question. Otherwise it takes the first cloze no sibling
+	// asks for.
+	cloze := card.Cloze
+	if !slices.Contains(numbers, cloze) {
+		cloze = 0
+		for _, n := range numbers {
+			if sib, ok := siblings[n]; !ok || sib.deleted {
+				cloze = n
+				break
+			}
+		}
+		if cloze == 0 && len(numbers) > 0 {
+			cloze = numbers[0]
+		}
+	}
+	if _, err := tx.Exec(`UPDATE cards SET cloze = ? WHERE id = ?`, cloze, card.ID); err != nil {
+		return 0, false, fmt.Errorf("failed to update card %s: %w", card.ID, err)
+	}
+
+	changed := false
+	for _, n := range numbers {
+		if n == cloze {
+			continue
+		}
+
+		sib := siblings[n]
+		delete(siblings, n)
+		saved, err := saveGeneratedCard(tx, deck, card.ID, sib.id, card.Title, card.Content, n, false, now)
+		if err != nil {
+			return 0, false, err
+		}
+		changed = changed || saved
+	}
+
+	// Whatever is left asks for a cloze the card no longer has.
+	for _, sib := range siblings {
+		if sib.deleted {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE id = ?`, now, GenerateID(), sib.id); err != nil {
+			return 0, false, fmt.Errorf("failed to delete cloze card %s: %w", sib.id, err)
+		}
+		changed = true
+	}
+
+	return cloze, changed, nil
+}
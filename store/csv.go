@@ -28,7 +28,11 @@ func ExportDeckToCSV(deck *models.Deck) (string, error) {
 	}
 
 	for _, card := range deck.Cards {
-		if err := writer.Write([]string{card.ID, card.Title, card.Content}); err != nil {
+		// Generated cards are made again when their source card is imported.
+		if card.SourceID != "" {
+			continue
+		}
+		if err := writer.Write([]string{card.ID, card.Title, exportContent(card)}); err != nil {
 			return "", fmt.Errorf("failed to write card to CSV: %w", err)
 		}
 	}
@@ -36,6 +40,58 @@ func ExportDeckToCSV(deck *models.Deck) (string, error) {
 	return csvData, nil
 }
 
+// ExportNotesToCSV writes the notes of one type in a deck as CSV, with a
+// column for the note ID followed by one per field.
+func (s *Store) ExportNotesToCSV(deckName string, noteTypeID string) (string, error) {
+	nt, err := s.NoteType(noteTypeID)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id FROM notes n
+		WHERE deck_id = ? AND note_type_id = ?
+			AND EXISTS (SELECT 1 FROM cards c WHERE c.note_id = n.id AND c.deleted_at IS NULL)
+		ORDER BY created_at, id
+	`, deckName, nt.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load notes: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", fmt.Errorf("failed to scan note: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var csvData string
+	writer := csv.NewWriter(&csvBuffer{&csvData})
+
+	if err := writer.Write(append([]string{"ID"}, nt.Fields...)); err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, id := range ids {
+		n, err := s.GetNote(id)
+		if err != nil {
+			return "", err
+		}
+		record := []string{n.ID}
+		for _, field := range nt.Fields {
+			record = append(record, n.Fields[field])
+		}
+		if err := writer.Write(record); err != nil {
+			return "", fmt.Errorf("failed to write note to CSV: %w", err)
+		}
+	}
+	writer.Flush()
+	return csvData, writer.Error()
+}
+
 type csvBuffer struct {
 	data *string
 }
@@ -45,7 +101,7 @@ func (b *csvBuffer) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func ImportCSVDeck(csvString string, deckName string) (*models.Deck, error) {
+func (s *Store) ImportCSVDeck(csvString string, deckName string) (*models.Deck, error) {
 	reader := csv.NewReader(&csvBufferReader{data: []byte(csvString)})
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -60,7 +116,7 @@ func ImportCSVDeck(csvString string, deckName string) (*models.Deck, error) {
 		deckName = "ImportedDeck"
 	}
 
-	deck := NewDeck(deckName)
+	deck := s.NewDeck(deckName)
 
 	for _, record := range records[1:] {
 		if len(record) < 3 {
@@ -75,13 +131,13 @@ func ImportCSVDeck(csvString string, deckName string) (*models.Deck, error) {
 			DeckID:  deckName,
 		}
 
-		if err := AddOrUpdateCard(deck, card); err != nil {
+		if err := s.AddOrUpdateCard(deck, card); err != nil {
 			logrus.Errorf("Failed to add card from CSV: %v", err)
 			continue
 		}
 	}
 
-	if err := SaveDeck(deck); err != nil {
+	if err := s.SaveDeck(deck); err != nil {
 		return nil, fmt.Errorf("failed to save imported deck: %w", err)
 	}
 
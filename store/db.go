@@ -1,55 +1,90 @@
 package store
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/dfirebaugh/mdsrs/models"
 	_ "modernc.org/sqlite"
 )
 
-var (
-	db     *sql.DB
-	dbPath string
-)
+// MemoryPath opens a private in-memory database, which is handy for tests.
+const MemoryPath = ":memory:"
 
-func GetDB() *sql.DB {
-	return db
+// Store is a collection of decks and cards backed by a SQLite database.
+type Store struct {
+	db      *sql.DB
+	path    string
+	backups *backupScheduler
 }
 
-func SetDBPath(path string) {
-	dbPath = path
-}
+// Open opens the database at path, creating and migrating it as needed. A
+// new database is seeded with a sample deck.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("database path not set")
+	}
+
+	isNewDB := path == MemoryPath
+	if !isNewDB {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			isNewDB = true
+		}
 
-func InitDB() error {
-	if dbPath == "" {
-		return fmt.Errorf("database path not set")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
 	}
 
-	var isNewDB bool
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		isNewDB = true
+	db, err := sql.Open("sqlite", dataSource(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
-		return fmt.Errorf("failed to create database directory: %w", err)
+	s := &Store{db: db, path: path}
+	if err := s.init(isNewDB); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// dataSource returns the name to open the database at path with.
+func dataSource(path string) string {
+	if path == MemoryPath {
+		// Every connection to :memory: is a separate database, so give the
+		// pool a named in-memory database of its own to share instead.
+		return "file:mdsrs-" + GenerateID() + "?mode=memory&cache=shared"
 	}
+	return path
+}
 
-	var err error
-	db, err = sql.Open("sqlite", dbPath)
+func (s *Store) init(isNewDB bool) error {
+	hadTags, err := s.tableExists("card_tags")
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 
-	if err := createTables(); err != nil {
+	if err := s.createTables(); err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	if err := s.migrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if !hadTags && !isNewDB {
+		if err := s.backfillTags(); err != nil {
+			return fmt.Errorf("failed to index tags: %w", err)
+		}
+	}
+
 	if isNewDB {
-		if err := initializeSampleData(); err != nil {
+		if err := s.initializeSampleData(); err != nil {
 			return fmt.Errorf("failed to initialize sample data: %w", err)
 		}
 	}
@@ -57,12 +92,32 @@ func InitDB() error {
 	return nil
 }
 
-func initializeSampleData() error {
+// DB returns the underlying database handle.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Path returns the file the store was opened from.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Close closes the database, taking a final backup first if backups were
+// started.
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	s.stopBackups()
+	return s.db.Close()
+}
+
+func (s *Store) initializeSampleData() error {
 	deck := &models.Deck{
 		Name: "Getting Started",
 	}
 
-	if err := SaveDeck(deck); err != nil {
+	if err := s.SaveDeck(deck); err != nil {
 		return fmt.Errorf("failed to save sample deck: %w", err)
 	}
 
@@ -89,7 +144,7 @@ func initializeSampleData() error {
 	}
 
 	for _, card := range cards {
-		if err := AddOrUpdateCard(deck, card); err != nil {
+		if err := s.AddOrUpdateCard(deck, card); err != nil {
 			return fmt.Errorf("failed to add sample card: %w", err)
 		}
 	}
@@ -97,16 +152,8 @@ func initializeSampleData() error {
 	return nil
 }
 
-func CloseDB(ctx context.Context) {
-	if db != nil {
-		if err := db.Close(); err != nil {
-			fmt.Printf("Error closing database: %v\n", err)
-		}
-	}
-}
-
-func createTables() error {
-	_, err := db.Exec(`
+func (s *Store) createTables() error {
+	_, err := s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS decks (
 			name TEXT PRIMARY KEY,
 			dir_path TEXT
@@ -116,7 +163,7 @@ func createTables() error {
 		return err
 	}
 
-	_, err = db.Exec(`
+	_, err = s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS cards (
 			id TEXT PRIMARY KEY,
 			deck_id TEXT,
@@ -129,7 +176,7 @@ func createTables() error {
 		return err
 	}
 
-	_, err = db.Exec(`
+	_, err = s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS srs_data (
 			card_id TEXT PRIMARY KEY,
 			last_review INTEGER,
@@ -143,5 +190,287 @@ func createTables() error {
 		return err
 	}
 
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS card_tags (
+			card_id TEXT NOT NULL,
+			tag TEXT NOT NULL COLLATE NOCASE,
+			manual INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY(card_id, tag),
+			FOREIGN KEY(card_id) REFERENCES cards(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_card_tags_tag ON card_tags(tag)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS card_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id TEXT NOT NULL,
+			title TEXT,
+			content TEXT,
+			created_at INTEGER NOT NULL,
+			FOREIGN KEY(card_id) REFERENCES cards(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_card_revisions_card ON card_revisions(card_id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS review_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id TEXT NOT NULL,
+			reviewed_at INTEGER NOT NULL,
+			outcome INTEGER NOT NULL,
+			next_review INTEGER NOT NULL,
+			ease_factor REAL NOT NULL,
+			FOREIGN KEY(card_id) REFERENCES cards(id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_review_log_card ON review_log(card_id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS note_types (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			fields TEXT NOT NULL,
+			templates TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS notes (
+			id TEXT PRIMARY KEY,
+			note_type_id TEXT NOT NULL,
+			deck_id TEXT,
+			fields TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Rendered HTML was cached in the collection before it moved to a
+	// RenderCache of its own.
+	if _, err := s.db.Exec(`DROP TABLE IF EXISTS render_cache`); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) tableExists(name string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", name, err)
+	}
+	return count > 0, nil
+}
+
+// backfillTags indexes the tags of cards written before tags were stored.
+func (s *Store) backfillTags() error {
+	rows, err := s.db.Query(`SELECT id, content FROM cards`)
+	if err != nil {
+		return err
+	}
+	contents := make(map[string]string)
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, content := range contents {
+		if err := syncCardTags(tx, id, content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// migrate adds columns introduced after the original schema to existing
+// databases.
+func (s *Store) migrate() error {
+	columns := []struct {
+		table, name, definition string
+	}{
+		{"cards", "suspended", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "created_at", "INTEGER"},
+		{"cards", "deleted_at", "INTEGER"},
+		{"decks", "deleted_at", "INTEGER"},
+		{"cards", "updated_at", "INTEGER"},
+		{"decks", "created_at", "INTEGER"},
+		{"decks", "updated_at", "INTEGER"},
+		{"cards", "position", "INTEGER"},
+		{"decks", "new_card_order", "TEXT NOT NULL DEFAULT 'position'"},
+		{"decks", "autoplay_audio", "INTEGER NOT NULL DEFAULT 1"},
+		{"cards", "flag", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "marked", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "note_id", "TEXT"},
+		{"cards", "ord", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "source_id", "TEXT"},
+		{"cards", "cloze", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "reverse", "INTEGER NOT NULL DEFAULT 0"},
+		{"cards", "source_url", "TEXT NOT NULL DEFAULT ''"},
+		{"cards", "trash_batch", "TEXT"},
+		{"decks", "trash_batch", "TEXT"},
+	}
+
+	for _, col := range columns {
+		if err := s.addColumn(col.table, col.name, col.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_cards_note ON cards(note_id, ord)`); err != nil {
+		return fmt.Errorf("failed to index cards by note: %w", err)
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_cards_source ON cards(source_id)`); err != nil {
+		return fmt.Errorf("failed to index cards by source: %w", err)
+	}
+
+	if err := s.backfillTimestamps(); err != nil {
+		return err
+	}
+
+	// Cards used to come back in insertion order, which rowid preserves.
+	if _, err := s.db.Exec(`UPDATE cards SET position = rowid WHERE position IS NULL`); err != nil {
+		return fmt.Errorf("failed to backfill card positions: %w", err)
+	}
+
+	// Things trashed before batches were recorded were trashed together if
+	// they were trashed in the same second.
+	for _, table := range []string{"cards", "decks"} {
+		_, err := s.db.Exec(`
+			UPDATE ` + table + ` SET trash_batch = CAST(deleted_at AS TEXT)
+			WHERE deleted_at IS NOT NULL AND trash_batch IS NULL
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to backfill trash batches: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// backfillTimestamps fills in timestamps for rows written before they were
+// tracked. Card creation falls back to the oldest revision, and decks take
+// their times from their cards.
+func (s *Store) backfillTimestamps() error {
+	now := time.Now().Unix()
+
+	_, err := s.db.Exec(`
+		UPDATE cards SET created_at = COALESCE(
+			(SELECT MIN(r.created_at) FROM card_revisions r WHERE r.card_id = cards.id),
+			?
+		)
+		WHERE created_at IS NULL
+	`, now)
+	if err != nil {
+		return fmt.Errorf("failed to backfill card creation times: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE cards SET updated_at = COALESCE(
+			(SELECT MAX(r.created_at) FROM card_revisions r WHERE r.card_id = cards.id),
+			created_at
+		)
+		WHERE updated_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill card modification times: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE decks SET created_at = COALESCE(
+			(SELECT MIN(c.created_at) FROM cards c WHERE c.deck_id = decks.name),
+			?
+		)
+		WHERE created_at IS NULL
+	`, now)
+	if err != nil {
+		return fmt.Errorf("failed to backfill deck creation times: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE decks SET updated_at = COALESCE(
+			(SELECT MAX(c.updated_at) FROM cards c WHERE c.deck_id = decks.name),
+			created_at
+		)
+		WHERE updated_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill deck modification times: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
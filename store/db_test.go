@@ -0,0 +1,64 @@
+package store
+
+import "testing"
+
+func TestOpenMemoryPathIsolated(t *testing.T) {
+	t.Parallel()
+
+	a, err := Open(MemoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := Open(MemoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	deck := a.NewDeck("only in a")
+	if err := a.AddCard(deck, Flashcard{Title: "card", Content: "card"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.LoadDeck("only in a"); err == nil {
+		t.Error("a deck added to one in-memory store is in another")
+	}
+	decks, err := b.LoadAllDecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range decks {
+		if d.Name == deck.Name {
+			t.Errorf("LoadAllDecks returned %q from another store", d.Name)
+		}
+	}
+}
+
+func TestOpenMemoryPathParallel(t *testing.T) {
+	for _, name := range []string{"first", "second", "third"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			st, err := Open(MemoryPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+
+			deck := st.NewDeck(name)
+			if err := st.AddCard(deck, Flashcard{Title: name, Content: name}); err != nil {
+				t.Fatal(err)
+			}
+			decks, err := st.LoadAllDecks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range decks {
+				if d.Name != name && d.Name != "Getting Started" {
+					t.Errorf("store for %q has deck %q", name, d.Name)
+				}
+			}
+		})
+	}
+}
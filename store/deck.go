@@ -3,6 +3,8 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/dfirebaugh/mdsrs/models"
 	"github.com/sirupsen/logrus"
@@ -10,16 +12,10 @@ import (
 
 type Deck = models.Deck
 
-func NewDeck(name string) *Deck {
+func (s *Store) NewDeck(name string) *Deck {
 	deck := &Deck{Name: name, Cards: []models.Flashcard{}}
 
-	_, err := db.Exec(`
-		INSERT INTO decks (name)
-		VALUES (?)
-		ON CONFLICT(name) DO NOTHING;
-	`, name)
-
-	if err != nil {
+	if err := s.insertDeck(deck); err != nil {
 		logrus.Errorf("Failed to create deck in database: %v", err)
 		return nil
 	}
@@ -27,11 +23,37 @@ func NewDeck(name string) *Deck {
 	return deck
 }
 
-func LoadDeck(deckName string) (*Deck, error) {
-	var name string
-	err := db.QueryRow(`
-		SELECT name FROM decks WHERE name = ?
-	`, deckName).Scan(&name)
+// insertDeck creates a deck row, or takes an existing one out of the trash,
+// and fills in the deck's timestamps.
+func (s *Store) insertDeck(deck *Deck) error {
+	now := time.Now().Unix()
+	_, err := s.db.Exec(`
+		INSERT INTO decks (name, created_at, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET deleted_at = NULL, trash_batch = NULL;
+	`, deck.Name, now, now)
+	if err != nil {
+		return err
+	}
+
+	return s.db.QueryRow(`SELECT created_at, updated_at, new_card_order, autoplay_audio FROM decks WHERE name = ?`, deck.Name).
+		Scan(&deck.CreatedAt, &deck.UpdatedAt, &deck.NewCardOrder, &deck.AutoplayAudio)
+}
+
+// touchDeck marks a deck as modified.
+func touchDeck(e execer, deck *Deck, now int64) error {
+	if _, err := e.Exec(`UPDATE decks SET updated_at = ? WHERE name = ?`, now, deck.Name); err != nil {
+		return fmt.Errorf("failed to update deck %s: %w", deck.Name, err)
+	}
+	deck.UpdatedAt = now
+	return nil
+}
+
+func (s *Store) LoadDeck(deckName string) (*Deck, error) {
+	deck := &Deck{Cards: []models.Flashcard{}}
+	err := s.db.QueryRow(`
+		SELECT name, created_at, updated_at, new_card_order, autoplay_audio FROM decks WHERE name = ? AND deleted_at IS NULL
+	`, deckName).Scan(&deck.Name, &deck.CreatedAt, &deck.UpdatedAt, &deck.NewCardOrder, &deck.AutoplayAudio)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("deck not found: %s", deckName)
@@ -40,12 +62,17 @@ func LoadDeck(deckName string) (*Deck, error) {
 		return nil, fmt.Errorf("failed to load deck: %w", err)
 	}
 
-	deck := &Deck{Name: name, Cards: []models.Flashcard{}}
+	tags, err := s.deckTags(deckName)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := db.Query(`
-		SELECT id, title, content
+	rows, err := s.db.Query(`
+		SELECT id, title, content, suspended, flag, marked, COALESCE(note_id, ''), ord,
+			COALESCE(source_id, ''), cloze, reverse, source_url, created_at, updated_at, position
 		FROM cards
-		WHERE deck_id = ?
+		WHERE deck_id = ? AND deleted_at IS NULL
+		ORDER BY position, rowid
 	`, deckName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load cards: %w", err)
@@ -55,19 +82,24 @@ func LoadDeck(deckName string) (*Deck, error) {
 	for rows.Next() {
 		var card models.Flashcard
 		card.DeckID = deckName
-		err := rows.Scan(&card.ID, &card.Title, &card.Content)
+		err := rows.Scan(&card.ID, &card.Title, &card.Content, &card.Suspended, &card.Flag, &card.Marked,
+			&card.NoteID, &card.Ord, &card.SourceID, &card.Cloze, &card.Reverse, &card.SourceURL, &card.CreatedAt, &card.UpdatedAt, &card.Position)
 		if err != nil {
 			logrus.Errorf("Failed to scan card: %v", err)
 			continue
 		}
+		card.Tags = tags[card.ID]
+		if card.Tags == nil {
+			card.Tags = []string{}
+		}
 		deck.Cards = append(deck.Cards, card)
 	}
 
 	return deck, nil
 }
 
-func LoadAllDecks() ([]*Deck, error) {
-	rows, err := db.Query(`SELECT name FROM decks`)
+func (s *Store) LoadAllDecks() ([]*Deck, error) {
+	rows, err := s.db.Query(`SELECT name FROM decks WHERE deleted_at IS NULL`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query decks: %w", err)
 	}
@@ -81,7 +113,7 @@ func LoadAllDecks() ([]*Deck, error) {
 			continue
 		}
 
-		deck, err := LoadDeck(deckName)
+		deck, err := s.LoadDeck(deckName)
 		if err != nil {
 			logrus.Errorf("Failed to load deck %s: %v", deckName, err)
 			continue
@@ -92,19 +124,17 @@ func LoadAllDecks() ([]*Deck, error) {
 	return decks, nil
 }
 
-func SaveDeck(deck *Deck) error {
-	_, err := db.Exec(`
-		INSERT INTO decks (name)
-		VALUES (?)
-		ON CONFLICT(name) DO NOTHING; 
-	`, deck.Name)
-
-	if err != nil {
+func (s *Store) SaveDeck(deck *Deck) error {
+	if err := s.insertDeck(deck); err != nil {
 		return fmt.Errorf("failed to save deck: %w", err)
 	}
 
-	for _, card := range deck.Cards {
-		if err := AddOrUpdateCard(deck, card); err != nil {
+	for _, card := range slices.Clone(deck.Cards) {
+		// Generated cards are saved along with the card they come from.
+		if card.SourceID != "" {
+			continue
+		}
+		if err := s.AddOrUpdateCard(deck, card); err != nil {
 			return fmt.Errorf("failed to save card %s: %w", card.ID, err)
 		}
 	}
@@ -112,20 +142,45 @@ func SaveDeck(deck *Deck) error {
 	return nil
 }
 
-func DeleteDeck(deckName string) error {
-	_, err := db.Exec(`
-		DELETE FROM cards WHERE deck_id = ?
-	`, deckName)
+// DeleteDeck moves a deck and its cards to the trash.
+func (s *Store) DeleteDeck(deckName string) error {
+	now := time.Now().Unix()
+	batch := GenerateID()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE deck_id = ? AND deleted_at IS NULL
+	`, now, batch, deckName)
 	if err != nil {
 		return fmt.Errorf("failed to delete cards for deck %s: %w", deckName, err)
 	}
 
-	_, err = db.Exec(`
-		DELETE FROM decks WHERE name = ?
-	`, deckName)
+	_, err = tx.Exec(`
+		UPDATE decks SET deleted_at = ?, trash_batch = ? WHERE name = ? AND deleted_at IS NULL
+	`, now, batch, deckName)
 	if err != nil {
 		return fmt.Errorf("failed to delete deck %s: %w", deckName, err)
 	}
 
+	return tx.Commit()
+}
+
+// SetAutoplayAudio sets whether a deck's sounds play when cards are shown
+// and revealed.
+func (s *Store) SetAutoplayAudio(deck *Deck, autoplay bool) error {
+	result, err := s.db.Exec(`UPDATE decks SET autoplay_audio = ? WHERE name = ? AND deleted_at IS NULL`, autoplay, deck.Name)
+	if err != nil {
+		return fmt.Errorf("failed to set audio autoplay: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("deck not found: %s", deck.Name)
+	}
+
+	deck.AutoplayAudio = autoplay
 	return nil
 }
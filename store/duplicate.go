@@ -0,0 +1,232 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// DefaultDuplicateThreshold is the similarity above which two fronts are
+// reported as near-duplicates.
+const DefaultDuplicateThreshold = 0.8
+
+// shingleSize is the length in runes of the substrings fronts are compared
+// by.
+const shingleSize = 3
+
+type frontShingles struct {
+	card       models.Flashcard
+	normalized string
+	shingles   map[string]struct{}
+}
+
+// FindDuplicates compares the fronts of the cards in a deck, or of every card
+// if deckName is empty, and returns the pairs at least threshold similar,
+// most similar first. A threshold of zero or less uses
+// DefaultDuplicateThreshold.
+func (s *Store) FindDuplicates(deckName string, threshold float64) ([]models.DuplicatePair, error) {
+	if threshold <= 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+cardColumns+`
+		FROM cards c
+		LEFT JOIN srs_data s ON s.card_id = c.id
+		WHERE c.deleted_at IS NULL AND c.source_id IS NULL AND (? = '' OR c.deck_id = ?)
+		ORDER BY c.deck_id, c.position, c.rowid
+	`, deckName, deckName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cards: %w", err)
+	}
+	cards, err := scanCards(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	fronts := make([]frontShingles, 0, len(cards))
+	for _, card := range cards {
+		normalized := normalizeFront(card)
+		if normalized == "" {
+			continue
+		}
+		fronts = append(fronts, frontShingles{
+			card:       card,
+			normalized: normalized,
+			shingles:   shingle(normalized),
+		})
+	}
+
+	// Two sets can only be threshold similar if the smaller is at least
+	// threshold times the size of the larger, so sorting by size lets the
+	// inner loop stop early.
+	sort.SliceStable(fronts, func(i, j int) bool {
+		return len(fronts[i].shingles) < len(fronts[j].shingles)
+	})
+
+	pairs := []models.DuplicatePair{}
+	for i := range fronts {
+		for j := i + 1; j < len(fronts); j++ {
+			a, b := fronts[i], fronts[j]
+			if float64(len(a.shingles)) < threshold*float64(len(b.shingles)) {
+				break
+			}
+
+			exact := a.normalized == b.normalized
+			similarity := 1.0
+			if !exact {
+				similarity = jaccard(a.shingles, b.shingles)
+			}
+			if similarity >= threshold {
+				pairs = append(pairs, models.DuplicatePair{
+					A:          a.card,
+					B:          b.card,
+					Similarity: similarity,
+					Exact:      exact,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Similarity > pairs[j].Similarity
+	})
+	return pairs, nil
+}
+
+// normalizeFront reduces a card's title and front to lowercase words
+// separated by single spaces, without markup or punctuation.
+func normalizeFront(card models.Flashcard) string {
+	front := card.Content
+	if parsed, err := md.ParseCard([]byte(card.Content)); err == nil {
+		front = parsed.Front
+	} else if i := strings.Index(front, "<card-back>"); i >= 0 {
+		front = front[:i]
+	}
+
+	text := md.PlainText([]byte(card.Title + "\n\n" + front))
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func shingle(s string) map[string]struct{} {
+	runes := []rune(s)
+	shingles := make(map[string]struct{})
+	if len(runes) <= shingleSize {
+		shingles[s] = struct{}{}
+		return shingles
+	}
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		shingles[string(runes[i:i+shingleSize])] = struct{}{}
+	}
+	return shingles
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for s := range a {
+		if _, ok := b[s]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// MergeCards merges a duplicate into the card kept. The kept card's content
+// stays, it takes whichever of the two SRS histories has more reviews, and
+// it gains the duplicate's manually added tags and review log. The
+// duplicate and the cloze and reverse cards generated from it are moved to
+// the trash together.
+func (s *Store) MergeCards(keepID string, duplicateID string) error {
+	if keepID == duplicateID {
+		return fmt.Errorf("cannot merge a card with itself")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	decks := make(map[string]string, 2)
+	sources := make(map[string]string, 2)
+	for _, id := range []string{keepID, duplicateID} {
+		var deck, source string
+		err := tx.QueryRow(`
+			SELECT deck_id, COALESCE(source_id, '') FROM cards WHERE id = ? AND deleted_at IS NULL
+		`, id).Scan(&deck, &source)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("card not found: %s", id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find card %s: %w", id, err)
+		}
+		decks[id] = deck
+		sources[id] = source
+	}
+	if sources[keepID] == duplicateID {
+		return fmt.Errorf("cannot merge card %s into a card generated from it", duplicateID)
+	}
+
+	// Replace the kept card's SRS data when the duplicate has been reviewed
+	// more, or as often but more recently.
+	_, err = tx.Exec(`
+		INSERT INTO srs_data (card_id, last_review, next_review, review_count, ease_factor)
+		SELECT ?, d.last_review, d.next_review, d.review_count, d.ease_factor
+		FROM srs_data d
+		LEFT JOIN srs_data k ON k.card_id = ?
+		WHERE d.card_id = ? AND (
+			k.card_id IS NULL
+			OR d.review_count > k.review_count
+			OR (d.review_count = k.review_count AND d.last_review > k.last_review)
+		)
+		ON CONFLICT(card_id) DO UPDATE SET
+			last_review = excluded.last_review,
+			next_review = excluded.next_review,
+			review_count = excluded.review_count,
+			ease_factor = excluded.ease_factor
+	`, keepID, keepID, duplicateID)
+	if err != nil {
+		return fmt.Errorf("failed to merge SRS data: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO card_tags (card_id, tag, manual)
+		SELECT ?, tag, 1 FROM card_tags WHERE card_id = ? AND manual = 1
+	`, keepID, duplicateID)
+	if err != nil {
+		return fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE review_log SET card_id = ? WHERE card_id = ?`, keepID, duplicateID); err != nil {
+		return fmt.Errorf("failed to merge review log: %w", err)
+	}
+
+	now := time.Now().Unix()
+	batch := GenerateID()
+	if _, err := tx.Exec(`UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE id = ?`, now, batch, duplicateID); err != nil {
+		return fmt.Errorf("failed to delete duplicate: %w", err)
+	}
+	if err := trashGeneratedCards(tx, duplicateID, now, batch); err != nil {
+		return err
+	}
+	for _, deck := range decks {
+		if err := touchDeck(tx, &Deck{Name: deck}, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
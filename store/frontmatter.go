@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// cardFrontMatter parses a card's front matter, rejecting values the store
+// can't apply, and fills in the card's title and source from it.
+func cardFrontMatter(card *models.Flashcard) (*md.FrontMatter, error) {
+	fm, _, err := md.ParseFrontMatter([]byte(card.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	card.SourceURL = ""
+	if fm == nil {
+		return nil, nil
+	}
+	if fm.Flag != "" {
+		if _, err := models.ParseFlag(fm.Flag); err != nil {
+			return nil, fmt.Errorf("invalid front matter: %w", err)
+		}
+	}
+	if fm.Title != "" {
+		card.Title = fm.Title
+	}
+	card.SourceURL = fm.Source
+	return fm, nil
+}
+
+// applyFrontMatter stores the settings given in a card's front matter. Keys
+// that aren't set leave the card as it is, and due and ease only schedule
+// cards that haven't been reviewed yet.
+func applyFrontMatter(e execer, cardID string, fm *md.FrontMatter) error {
+	if fm == nil {
+		return nil
+	}
+
+	var flag any
+	if fm.Flag != "" {
+		f, _ := models.ParseFlag(fm.Flag)
+		flag = int(f)
+	}
+	_, err := e.Exec(`
+		UPDATE cards SET
+			suspended = COALESCE(?, suspended),
+			marked = COALESCE(?, marked),
+			flag = COALESCE(?, flag)
+		WHERE id = ?
+	`, fm.Suspended, fm.Marked, flag, cardID)
+	if err != nil {
+		return fmt.Errorf("failed to apply front matter to card %s: %w", cardID, err)
+	}
+
+	due, hasDue := fm.DueTime()
+	if !hasDue && fm.Ease == 0 {
+		return nil
+	}
+	// A card with no SRS data is new; giving it an ease alone makes it due
+	// now, on the ease given.
+	nextReview := time.Now().Unix()
+	if hasDue {
+		nextReview = due.Unix()
+	}
+	ease := fm.Ease
+	if ease == 0 {
+		ease = 1.0
+	}
+
+	_, err = e.Exec(`
+		INSERT INTO srs_data (card_id, last_review, next_review, review_count, ease_factor)
+		VALUES (?, 0, ?, 0, ?)
+		ON CONFLICT(card_id) DO UPDATE SET
+			next_review = CASE WHEN ? THEN excluded.next_review ELSE next_review END,
+			ease_factor = CASE WHEN ? THEN excluded.ease_factor ELSE ease_factor END
+		WHERE review_count = 0
+	`, cardID, nextReview, ease, hasDue, fm.Ease != 0)
+	if err != nil {
+		return fmt.Errorf("failed to schedule card %s: %w", cardID, err)
+	}
+	return nil
+}
+
+// exportContent returns a card's markdown with its flag, mark and suspension
+// written into the front matter, so that importing it restores them.
+func exportContent(card models.Flashcard) string {
+	if card.Flag == models.FlagNone && !card.Marked && !card.Suspended {
+		return card.Content
+	}
+
+	fm, _, err := md.ParseFrontMatter([]byte(card.Content))
+	if err != nil {
+		return card.Content
+	}
+	if fm == nil {
+		fm = &md.FrontMatter{}
+	}
+	if card.Flag != models.FlagNone {
+		fm.Flag = models.FlagNames[card.Flag]
+	}
+	if card.Marked {
+		fm.Marked = &card.Marked
+	}
+	if card.Suspended {
+		fm.Suspended = &card.Suspended
+	}
+
+	content, err := md.SetFrontMatter([]byte(card.Content), fm)
+	if err != nil {
+		return card.Content
+	}
+	return string(content)
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/note"
+)
+
+// syncGeneratedCards brings the cloze and reverse cards generated from a card
+// in line with its content, as part of tx. It returns the cloze number the
+// card itself asks for and whether any generated card changed, in which case
+// the deck's cards need reloading once tx is committed. Cards that were
+// themselves generated, from a note or another card, don't generate more.
+func syncGeneratedCards(tx *sql.Tx, deck *Deck, card models.Flashcard, now int64) (int, bool, error) {
+	if card.NoteID != "" || card.SourceID != "" {
+		return card.Cloze, false, nil
+	}
+	cloze, clozeChanged, err := syncClozeCards(tx, deck, card, now)
+	if err != nil {
+		return 0, false, err
+	}
+	reverseChanged, err := syncReverseCard(tx, deck, card, now)
+	if err != nil {
+		return 0, false, err
+	}
+
+	changed := clozeChanged || reverseChanged
+	if changed {
+		if err := touchDeck(tx, deck, now); err != nil {
+			return 0, false, err
+		}
+	}
+	return cloze, changed, nil
+}
+
+// syncReverseCard generates a card with the front and back swapped for a card
+// with a <card-reverse/> directive and a back, and trashes it once the
+// directive or the back is removed. Cloze cards aren't reversed.
+func syncReverseCard(tx *sql.Tx, deck *Deck, card models.Flashcard, now int64) (bool, error) {
+	parsed, err := md.ParseCard([]byte(card.Content))
+	want := err == nil && parsed.Reverse && parsed.Back != "" && len(md.ClozeNumbers([]byte(card.Content))) == 0
+
+	var id string
+	var deleted bool
+	err = tx.QueryRow(`
+		SELECT id, deleted_at IS NOT NULL FROM cards WHERE source_id = ? AND reverse = 1
+	`, card.ID).Scan(&id, &deleted)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to find reverse card: %w", err)
+	}
+
+	switch {
+	case want:
+		content := parsed.Reversed()
+		return saveGeneratedCard(tx, deck, card.ID, id, note.Title(content), content, 0, true, now)
+	case id != "" && !deleted:
+		if _, err := tx.Exec(`UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE id = ?`, now, GenerateID(), id); err != nil {
+			return false, fmt.Errorf("failed to delete reverse card %s: %w", id, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// saveGeneratedCard adds a card generated from sourceID to deck, or, if id is
+// set, updates that card and takes it out of the trash. It reports whether
+// anything changed.
+func saveGeneratedCard(tx *sql.Tx, deck *Deck, sourceID, id, title, content string, cloze int, reverse bool, now int64) (bool, error) {
+	changed := true
+	if id == "" {
+		id = GenerateID()
+		_, err := tx.Exec(`
+			INSERT INTO cards (id, deck_id, title, content, created_at, updated_at, position, source_id, cloze, reverse)
+			VALUES (?, ?, ?, ?, ?, ?, `+nextPosition+`, ?, ?, ?)
+		`, id, deck.Name, title, content, now, now, deck.Name, sourceID, cloze, reverse)
+		if err != nil {
+			return false, fmt.Errorf("failed to add card generated from %s: %w", sourceID, err)
+		}
+	} else {
+		result, err := tx.Exec(`
+			UPDATE cards SET
+				updated_at = ?,
+				deck_id = ?,
+				title = ?,
+				content = ?,
+				deleted_at = NULL,
+				trash_batch = NULL
+			WHERE id = ? AND (
+				deck_id IS NOT ? OR title IS NOT ? OR content IS NOT ? OR deleted_at IS NOT NULL
+			)
+		`, now, deck.Name, title, content, id, deck.Name, title, content)
+		if err != nil {
+			return false, fmt.Errorf("failed to update card %s: %w", id, err)
+		}
+		n, _ := result.RowsAffected()
+		changed = n > 0
+	}
+
+	return changed, syncCardTags(tx, id, content)
+}
+
+// reloadCards replaces a deck's cards with what is in the database, after
+// generated cards were added or removed.
+func (s *Store) reloadCards(deck *Deck) error {
+	updated, err := s.LoadDeck(deck.Name)
+	if err != nil {
+		return err
+	}
+	deck.Cards = updated.Cards
+	return nil
+}
+
+// trashGeneratedCards moves the cards generated from a card to the trash
+// along with it, in the card's trash batch.
+func trashGeneratedCards(e execer, cardID string, now int64, batch string) error {
+	_, err := e.Exec(`
+		UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE source_id = ? AND deleted_at IS NULL
+	`, now, batch, cardID)
+	if err != nil {
+		return fmt.Errorf("failed to delete cards generated from %s: %w", cardID, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMediaName = errors.New("invalid media name")
+
+// mediaTypes maps the extensions of media files that can be added to the
+// type they are served as.
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".svg":  "image/svg+xml",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".webm": "audio/webm",
+}
+
+// detectedExtensions gives media without a file name an extension from the
+// type detected from its content.
+var detectedExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"application/ogg": ".ogg",
+}
+
+// MediaDir returns the media directory for a database file.
+func MediaDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "media")
+}
+
+// MediaType returns the content type of a media file name, or "" if files
+// like it can't be added.
+func MediaType(name string) string {
+	return mediaTypes[strings.ToLower(filepath.Ext(name))]
+}
+
+// AddMedia stores a media file and returns the name cards refer to it by.
+// Files are named by a hash of their content, so adding the same file twice
+// stores it once. name is only used for its extension; when it has none the
+// type is detected from data.
+func (s *Store) AddMedia(name string, data []byte) (string, error) {
+	if s.path == MemoryPath {
+		return "", fmt.Errorf("in-memory databases cannot store media")
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		ext = detectedExtensions[http.DetectContentType(data)]
+	}
+	if mediaTypes[ext] == "" {
+		return "", fmt.Errorf("unsupported media type: %s", name)
+	}
+
+	sum := sha256.Sum256(data)
+	mediaName := hex.EncodeToString(sum[:]) + ext
+
+	dir := MediaDir(s.path)
+	path := filepath.Join(dir, mediaName)
+	if _, err := os.Stat(path); err == nil {
+		// Touch the file so that CollectMedia gives the card about to refer
+		// to it time to be saved.
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return "", fmt.Errorf("failed to add media: %w", err)
+		}
+		return mediaName, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to add media: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to add media: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to add media: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to add media: %w", err)
+	}
+	return mediaName, nil
+}
+
+// MediaPath returns the path of a stored media file.
+func (s *Store) MediaPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidMediaName, name)
+	}
+	return filepath.Join(MediaDir(s.path), name), nil
+}
+
+// CollectMedia deletes media files last changed at or before the given unix
+// time that no card, note or card revision refers to, including those in the
+// trash and in the database's backups, so that restoring a backup doesn't
+// lose its media. It returns how many files were deleted.
+func (s *Store) CollectMedia(before int64) (int, error) {
+	if s.path == MemoryPath {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(MediaDir(s.path))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read media directory: %w", err)
+	}
+
+	var content strings.Builder
+	if err := readMediaReferences(s.db, &content); err != nil {
+		return 0, err
+	}
+
+	dir := BackupDir(s.path)
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, backup := range backups {
+		// A backup that can't be read might refer to anything, so nothing
+		// is deleted.
+		if err := readBackupMediaReferences(filepath.Join(dir, backup.Name), &content); err != nil {
+			return 0, fmt.Errorf("failed to read backup %s: %w", backup.Name, err)
+		}
+	}
+	referenced := content.String()
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || strings.Contains(referenced, entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(time.Unix(before, 0)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(MediaDir(s.path), entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to delete media %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// mediaSources are the columns that can refer to media files, by table.
+var mediaSources = []struct{ table, column string }{
+	{"cards", "content"},
+	{"notes", "fields"},
+	{"card_revisions", "COALESCE(content, '')"},
+}
+
+// readMediaReferences writes everything in db that can refer to media files
+// to out. Tables a database from an older version doesn't have yet are
+// skipped.
+func readMediaReferences(db *sql.DB, out *strings.Builder) error {
+	for _, source := range mediaSources {
+		var count int
+		err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, source.table).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to look up table %s: %w", source.table, err)
+		}
+		if count == 0 {
+			continue
+		}
+
+		rows, err := db.Query(`SELECT ` + source.column + ` FROM ` + source.table)
+		if err != nil {
+			return fmt.Errorf("failed to load card content: %w", err)
+		}
+		for rows.Next() {
+			var text string
+			if err := rows.Scan(&text); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to load card content: %w", err)
+			}
+			out.WriteString(text)
+			out.WriteByte('\n')
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to load card content: %w", err)
+		}
+	}
+	return nil
+}
+
+// readBackupMediaReferences opens a backup read-only and writes everything
+// in it that can refer to media files to out.
+func readBackupMediaReferences(path string, out *strings.Builder) error {
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	return readMediaReferences(db, out)
+}
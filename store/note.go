@@ -0,0 +1,336 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/note"
+)
+
+// ListNoteTypes returns the built-in note types followed by the
+// collection's own.
+func (s *Store) ListNoteTypes() ([]models.NoteType, error) {
+	types := note.BuiltinTypes()
+
+	rows, err := s.db.Query(`SELECT id, name, fields, templates FROM note_types ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list note types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		nt, err := scanNoteType(rows)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, nt)
+	}
+	return types, rows.Err()
+}
+
+// NoteType returns a built-in or custom note type.
+func (s *Store) NoteType(id string) (models.NoteType, error) {
+	return noteType(s.db, id)
+}
+
+func noteType(q queryer, id string) (models.NoteType, error) {
+	if nt, ok := note.Builtin(id); ok {
+		return nt, nil
+	}
+
+	rows, err := q.Query(`SELECT id, name, fields, templates FROM note_types WHERE id = ?`, id)
+	if err != nil {
+		return models.NoteType{}, fmt.Errorf("failed to load note type: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.NoteType{}, fmt.Errorf("failed to load note type: %w", err)
+		}
+		return models.NoteType{}, fmt.Errorf("note type not found: %s", id)
+	}
+	return scanNoteType(rows)
+}
+
+func scanNoteType(rows *sql.Rows) (models.NoteType, error) {
+	var nt models.NoteType
+	var fields, templates string
+	if err := rows.Scan(&nt.ID, &nt.Name, &fields, &templates); err != nil {
+		return nt, fmt.Errorf("failed to scan note type: %w", err)
+	}
+	if err := json.Unmarshal([]byte(fields), &nt.Fields); err != nil {
+		return nt, fmt.Errorf("invalid fields for note type %s: %w", nt.ID, err)
+	}
+	if err := json.Unmarshal([]byte(templates), &nt.Templates); err != nil {
+		return nt, fmt.Errorf("invalid templates for note type %s: %w", nt.ID, err)
+	}
+	return nt, nil
+}
+
+// AddNoteType creates a custom note type. Note types can't be edited once
+// created, since their cards would have to be regenerated.
+func (s *Store) AddNoteType(nt models.NoteType) (models.NoteType, error) {
+	if err := note.Validate(nt); err != nil {
+		return nt, err
+	}
+	if nt.ID == "" {
+		nt.ID = GenerateID()
+	}
+	if _, ok := note.Builtin(nt.ID); ok {
+		return nt, fmt.Errorf("note type already exists: %s", nt.ID)
+	}
+	nt.Builtin = false
+
+	fields, err := json.Marshal(nt.Fields)
+	if err != nil {
+		return nt, err
+	}
+	templates, err := json.Marshal(nt.Templates)
+	if err != nil {
+		return nt, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO note_types (id, name, fields, templates) VALUES (?, ?, ?, ?)
+	`, nt.ID, nt.Name, string(fields), string(templates))
+	if err != nil {
+		return nt, fmt.Errorf("failed to add note type: %w", err)
+	}
+	return nt, nil
+}
+
+// DeleteNoteType deletes a custom note type no notes use.
+func (s *Store) DeleteNoteType(id string) error {
+	if _, ok := note.Builtin(id); ok {
+		return fmt.Errorf("cannot delete built-in note type %s", id)
+	}
+
+	var used bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM notes WHERE note_type_id = ?)`, id).Scan(&used); err != nil {
+		return fmt.Errorf("failed to check note type usage: %w", err)
+	}
+	if used {
+		return fmt.Errorf("note type %s is in use", id)
+	}
+
+	result, err := s.db.Exec(`DELETE FROM note_types WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete note type: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("note type not found: %s", id)
+	}
+	return nil
+}
+
+// GetNote returns a note by ID.
+func (s *Store) GetNote(id string) (models.Note, error) {
+	var n models.Note
+	var deckID sql.NullString
+	var fields string
+	err := s.db.QueryRow(`
+		SELECT id, note_type_id, deck_id, fields, created_at, updated_at FROM notes WHERE id = ?
+	`, id).Scan(&n.ID, &n.NoteTypeID, &deckID, &fields, &n.CreatedAt, &n.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return n, fmt.Errorf("note not found: %s", id)
+	}
+	if err != nil {
+		return n, fmt.Errorf("failed to load note: %w", err)
+	}
+	n.DeckID = deckID.String
+	if err := json.Unmarshal([]byte(fields), &n.Fields); err != nil {
+		return n, fmt.Errorf("invalid fields for note %s: %w", id, err)
+	}
+	return n, nil
+}
+
+// AddOrUpdateNote saves a note in a deck and brings its cards in line with
+// it: one card per template whose front isn't empty. New cards go to the end
+// of the deck; existing ones stay where they are, and cards whose front has
+// become empty are moved to the trash.
+func (s *Store) AddOrUpdateNote(deck *Deck, n models.Note) (models.Note, error) {
+	if n.ID == "" {
+		n.ID = GenerateID()
+	}
+	n.DeckID = deck.Name
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return n, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	nt, err := noteType(tx, n.NoteTypeID)
+	if err != nil {
+		return n, err
+	}
+	if n.Fields, err = noteFields(nt, n.Fields); err != nil {
+		return n, err
+	}
+	fields, err := json.Marshal(n.Fields)
+	if err != nil {
+		return n, err
+	}
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		INSERT INTO notes (id, note_type_id, deck_id, fields, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			updated_at = CASE WHEN fields IS NOT excluded.fields THEN excluded.updated_at ELSE updated_at END,
+			fields = excluded.fields
+	`, n.ID, nt.ID, deck.Name, string(fields), now, now)
+	if err != nil {
+		return n, fmt.Errorf("failed to save note: %w", err)
+	}
+
+	var noteTypeID string
+	err = tx.QueryRow(`SELECT note_type_id, deck_id, created_at, updated_at FROM notes WHERE id = ?`, n.ID).
+		Scan(&noteTypeID, &n.DeckID, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return n, fmt.Errorf("failed to read note: %w", err)
+	}
+	if noteTypeID != nt.ID {
+		return n, fmt.Errorf("note %s is a %s note, not %s", n.ID, noteTypeID, nt.ID)
+	}
+
+	cards := 0
+	for ord := range nt.Templates {
+		title, content, ok := note.Card(nt, ord, n.Fields)
+		if err := syncNoteCard(tx, n, ord, title, content, ok, now); err != nil {
+			return n, err
+		}
+		if ok {
+			cards++
+		}
+	}
+	if cards == 0 {
+		return n, fmt.Errorf("note would have no cards: the front of every card is empty")
+	}
+
+	if err := touchDeck(tx, deck, now); err != nil {
+		return n, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return n, fmt.Errorf("failed to commit note: %w", err)
+	}
+	return n, nil
+}
+
+// noteFields checks fields against a note type, filling in missing ones with
+// empty values and using the note type's spelling of each name.
+func noteFields(nt models.NoteType, fields map[string]string) (map[string]string, error) {
+	normalized := make(map[string]string, len(nt.Fields))
+	for _, name := range nt.Fields {
+		normalized[name] = ""
+	}
+
+	for name, value := range fields {
+		found := false
+		for _, field := range nt.Fields {
+			if strings.EqualFold(name, field) {
+				normalized[field] = value
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("note type %s has no field %q", nt.Name, name)
+		}
+	}
+	return normalized, nil
+}
+
+// syncNoteCard creates, updates or trashes the card for template ord of a
+// note.
+func syncNoteCard(tx *sql.Tx, n models.Note, ord int, title, content string, keep bool, now int64) error {
+	var id, deckID string
+	var deleted bool
+	err := tx.QueryRow(`
+		SELECT id, deck_id, deleted_at IS NOT NULL FROM cards WHERE note_id = ? AND ord = ?
+	`, n.ID, ord).Scan(&id, &deckID, &deleted)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to find card for note %s: %w", n.ID, err)
+	}
+	exists := err == nil
+
+	switch {
+	case !keep && exists && !deleted:
+		if _, err := tx.Exec(`UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE id = ?`, now, GenerateID(), id); err != nil {
+			return fmt.Errorf("failed to delete card %s: %w", id, err)
+		}
+		return touchDeck(tx, &Deck{Name: deckID}, now)
+	case !keep:
+		return nil
+	case !exists:
+		id = GenerateID()
+		_, err := tx.Exec(`
+			INSERT INTO cards (id, deck_id, title, content, created_at, updated_at, position, note_id, ord)
+			VALUES (?, ?, ?, ?, ?, ?, `+nextPosition+`, ?, ?)
+		`, id, n.DeckID, title, content, now, now, n.DeckID, n.ID, ord)
+		if err != nil {
+			return fmt.Errorf("failed to add card for note %s: %w", n.ID, err)
+		}
+	default:
+		if err := snapshotCard(tx, id, title, content); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			UPDATE cards SET
+				updated_at = CASE WHEN title IS NOT ? OR content IS NOT ? THEN ? ELSE updated_at END,
+				title = ?,
+				content = ?,
+				deleted_at = NULL,
+				trash_batch = NULL
+			WHERE id = ?
+		`, title, content, now, title, content, id)
+		if err != nil {
+			return fmt.Errorf("failed to update card %s: %w", id, err)
+		}
+		if deckID != n.DeckID {
+			if err := touchDeck(tx, &Deck{Name: deckID}, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return syncCardTags(tx, id, content)
+}
+
+// DeleteNote moves a note's cards to the trash. The note itself is deleted
+// when the trash is purged.
+func (s *Store) DeleteNote(id string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	_, err = tx.Exec(`
+		UPDATE decks SET updated_at = ?
+		WHERE name IN (SELECT deck_id FROM cards WHERE note_id = ? AND deleted_at IS NULL)
+	`, now, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update decks: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE cards SET deleted_at = ?, trash_batch = ? WHERE note_id = ? AND deleted_at IS NULL
+	`, now, GenerateID(), id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete note: %w", err)
+	}
+	n, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit note deletion: %w", err)
+	}
+	return int(n), nil
+}
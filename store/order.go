@@ -0,0 +1,95 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// MoveCard moves a card to index within its deck, shifting the cards after
+// it down. Positions in the deck are renumbered from zero.
+func (s *Store) MoveCard(deck *Deck, cardID string, index int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM cards
+		WHERE deck_id = ? AND deleted_at IS NULL
+		ORDER BY position, rowid
+	`, deck.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load card order: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan card: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load card order: %w", err)
+	}
+
+	from := slices.Index(ids, cardID)
+	if from < 0 {
+		return fmt.Errorf("card not found: %s", cardID)
+	}
+	index = max(0, min(index, len(ids)-1))
+	ids = slices.Insert(slices.Delete(ids, from, from+1), index, cardID)
+
+	for i, id := range ids {
+		if _, err := tx.Exec(`UPDATE cards SET position = ? WHERE id = ?`, i, id); err != nil {
+			return fmt.Errorf("failed to update card position: %w", err)
+		}
+	}
+	if err := touchDeck(tx, deck, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+	for i := range deck.Cards {
+		if p, ok := positions[deck.Cards[i].ID]; ok {
+			deck.Cards[i].Position = p
+		}
+	}
+	slices.SortStableFunc(deck.Cards, func(a, b models.Flashcard) int {
+		return a.Position - b.Position
+	})
+
+	return nil
+}
+
+// SetNewCardOrder sets whether a deck's new cards are introduced in position
+// order or randomly.
+func (s *Store) SetNewCardOrder(deck *Deck, order string) error {
+	if order != models.NewCardOrderPosition && order != models.NewCardOrderRandom {
+		return fmt.Errorf("invalid new card order %q", order)
+	}
+
+	result, err := s.db.Exec(`UPDATE decks SET new_card_order = ? WHERE name = ? AND deleted_at IS NULL`, order, deck.Name)
+	if err != nil {
+		return fmt.Errorf("failed to set new card order: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("deck not found: %s", deck.Name)
+	}
+
+	deck.NewCardOrder = order
+	return nil
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RenderCacheSize is the number of rendered documents Prune keeps by
+// default.
+const RenderCacheSize = 10000
+
+// RenderCache persists rendered HTML in a database of its own, so that the
+// collection and its backups don't carry it. It can be set as
+// md.DefaultCache's store.
+type RenderCache struct {
+	db *sql.DB
+
+	mu sync.Mutex
+	// used holds when documents were last loaded until they are written out
+	// together, so that loading a document doesn't write to the database.
+	used map[string]int64
+}
+
+// RenderCachePath returns the render cache file for a database file.
+func RenderCachePath(dbPath string) string {
+	if dbPath == MemoryPath {
+		return MemoryPath
+	}
+	return strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + ".render.db"
+}
+
+// OpenRenderCache opens the render cache at path, creating it as needed.
+func OpenRenderCache(path string) (*RenderCache, error) {
+	if path != MemoryPath {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create render cache directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dataSource(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open render cache: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS render_cache (
+			key TEXT PRIMARY KEY,
+			html TEXT NOT NULL,
+			used_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create render cache: %w", err)
+	}
+
+	return &RenderCache{db: db, used: make(map[string]int64)}, nil
+}
+
+// LoadHTML returns the HTML cached for a render cache key.
+func (c *RenderCache) LoadHTML(key string) (string, bool, error) {
+	var html string
+	err := c.db.QueryRow(`SELECT html FROM render_cache WHERE key = ?`, key).Scan(&html)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to load rendered html: %w", err)
+	}
+
+	c.mu.Lock()
+	c.used[key] = time.Now().Unix()
+	c.mu.Unlock()
+	return html, true, nil
+}
+
+// SaveHTML caches the HTML rendered for a key.
+func (c *RenderCache) SaveHTML(key string, html string) error {
+	_, err := c.db.Exec(`
+		INSERT INTO render_cache (key, html, used_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET html = excluded.html, used_at = excluded.used_at
+	`, key, html, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save rendered html: %w", err)
+	}
+	return nil
+}
+
+// flushUsed writes when documents were last loaded.
+func (c *RenderCache) flushUsed() error {
+	c.mu.Lock()
+	used := c.used
+	c.used = make(map[string]int64)
+	c.mu.Unlock()
+	if len(used) == 0 {
+		return nil
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, usedAt := range used {
+		if _, err := tx.Exec(`UPDATE render_cache SET used_at = MAX(used_at, ?) WHERE key = ?`, usedAt, key); err != nil {
+			return fmt.Errorf("failed to update render cache: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit render cache: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes all but the keep most recently used rendered documents, and
+// returns how many were deleted.
+func (c *RenderCache) Prune(keep int) (int, error) {
+	if err := c.flushUsed(); err != nil {
+		return 0, err
+	}
+
+	res, err := c.db.Exec(`
+		DELETE FROM render_cache WHERE key NOT IN (
+			SELECT key FROM render_cache ORDER BY used_at DESC LIMIT ?
+		)
+	`, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune render cache: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune render cache: %w", err)
+	}
+	return int(n), nil
+}
+
+// Clear deletes every rendered document.
+func (c *RenderCache) Clear() error {
+	c.mu.Lock()
+	clear(c.used)
+	c.mu.Unlock()
+
+	if _, err := c.db.Exec(`DELETE FROM render_cache`); err != nil {
+		return fmt.Errorf("failed to clear render cache: %w", err)
+	}
+	return nil
+}
+
+// Close writes when documents were last loaded and closes the cache.
+func (c *RenderCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	err := c.flushUsed()
+	if closeErr := c.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+// snapshotCard records the current title and content of a card as a revision
+// if they differ from the given ones. It is called before every update.
+func snapshotCard(e execer, cardID string, title string, content string) error {
+	_, err := e.Exec(`
+		INSERT INTO card_revisions (card_id, title, content, created_at)
+		SELECT id, title, content, ?
+		FROM cards
+		WHERE id = ? AND (title IS NOT ? OR content IS NOT ?)
+	`, time.Now().Unix(), cardID, title, content)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot card %s: %w", cardID, err)
+	}
+	return nil
+}
+
+// ListCardRevisions returns the previous versions of a card, newest first.
+func (s *Store) ListCardRevisions(cardID string) ([]models.CardRevision, error) {
+	rows, err := s.db.Query(`
+		SELECT id, card_id, title, content, created_at
+		FROM card_revisions
+		WHERE card_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.CardRevision{}
+	for rows.Next() {
+		var rev models.CardRevision
+		if err := rows.Scan(&rev.ID, &rev.CardID, &rev.Title, &rev.Content, &rev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetCardRevision returns a single revision of a card. Revision ID 0 refers
+// to the card's current title and content.
+func (s *Store) GetCardRevision(cardID string, revisionID int64) (*models.CardRevision, error) {
+	rev := models.CardRevision{ID: revisionID, CardID: cardID}
+
+	var err error
+	if revisionID == 0 {
+		err = s.db.QueryRow(`
+			SELECT title, content FROM cards WHERE id = ?
+		`, cardID).Scan(&rev.Title, &rev.Content)
+		rev.CreatedAt = time.Now().Unix()
+	} else {
+		err = s.db.QueryRow(`
+			SELECT title, content, created_at
+			FROM card_revisions
+			WHERE id = ? AND card_id = ?
+		`, revisionID, cardID).Scan(&rev.Title, &rev.Content, &rev.CreatedAt)
+	}
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision %d not found for card %s", revisionID, cardID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revision: %w", err)
+	}
+	return &rev, nil
+}
+
+// RestoreCardRevision makes an old revision the card's current version. The
+// version being replaced is kept as a new revision.
+func (s *Store) RestoreCardRevision(deck *Deck, cardID string, revisionID int64) (models.Flashcard, error) {
+	rev, err := s.GetCardRevision(cardID, revisionID)
+	if err != nil {
+		return models.Flashcard{}, err
+	}
+
+	card := models.Flashcard{
+		DeckID:  deck.Name,
+		ID:      cardID,
+		Title:   rev.Title,
+		Content: rev.Content,
+	}
+	if err := s.AddOrUpdateCard(deck, card); err != nil {
+		return models.Flashcard{}, err
+	}
+
+	for _, c := range deck.Cards {
+		if c.ID == cardID {
+			return c, nil
+		}
+	}
+	return card, nil
+}
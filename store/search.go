@@ -0,0 +1,88 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/models"
+	"github.com/dfirebaugh/mdsrs/query"
+)
+
+// cardColumns selects a card with its SRS data and tags from cards c joined
+// with srs_data s, for scanCards.
+const cardColumns = `c.id, c.deck_id, c.title, c.content, c.suspended, c.flag, c.marked,
+			COALESCE(c.note_id, ''), c.ord, COALESCE(c.source_id, ''), c.cloze, c.reverse, c.source_url, c.created_at, c.updated_at,
+			s.next_review, s.review_count, s.ease_factor,
+			(SELECT group_concat(tag, ' ') FROM card_tags WHERE card_id = c.id)`
+
+// SearchOptions control the order and number of search results.
+type SearchOptions struct {
+	// SortBy is a field accepted by query.OrderBy.
+	SortBy     string
+	Descending bool
+	// Limit caps the number of results. Zero or less returns every match.
+	Limit int
+}
+
+// SearchCards returns the cards matching a query expression (see package
+// query).
+func (s *Store) SearchCards(expr string, opts SearchOptions) ([]models.Flashcard, error) {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search %q: %w", expr, err)
+	}
+
+	orderBy, err := query.OrderBy(opts.SortBy, opts.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := q.Where(time.Now().Unix())
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(`
+		SELECT `+cardColumns+`
+		FROM cards c
+		LEFT JOIN srs_data s ON s.card_id = c.id
+		WHERE c.deleted_at IS NULL AND `+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search cards: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCards(rows)
+}
+
+// scanCards reads the cards from a query selecting cardColumns.
+func scanCards(rows *sql.Rows) ([]models.Flashcard, error) {
+	cards := []models.Flashcard{}
+	for rows.Next() {
+		var card models.Flashcard
+		var nextReview, reviewCount sql.NullInt64
+		var easeFactor sql.NullFloat64
+		var tags sql.NullString
+		err := rows.Scan(&card.ID, &card.DeckID, &card.Title, &card.Content, &card.Suspended,
+			&card.Flag, &card.Marked, &card.NoteID, &card.Ord, &card.SourceID, &card.Cloze, &card.Reverse, &card.SourceURL, &card.CreatedAt, &card.UpdatedAt, &nextReview, &reviewCount, &easeFactor, &tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		card.NextReview = nextReview.Int64
+		card.ReviewCount = reviewCount.Int64
+		card.EaseFactor = easeFactor.Float64
+		card.Tags = strings.Fields(tags.String)
+		sort.Strings(card.Tags)
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
@@ -0,0 +1,407 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dfirebaugh/mdsrs/md"
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// syncCardTags replaces the tags extracted from a card's markdown. Tags added
+// with AddTags are kept.
+func syncCardTags(e execer, cardID string, content string) error {
+	if _, err := e.Exec(`DELETE FROM card_tags WHERE card_id = ? AND manual = 0`, cardID); err != nil {
+		return fmt.Errorf("failed to clear tags for card %s: %w", cardID, err)
+	}
+
+	for _, tag := range md.ExtractTags([]byte(content)) {
+		_, err := e.Exec(`
+			INSERT INTO card_tags (card_id, tag)
+			VALUES (?, ?)
+			ON CONFLICT(card_id, tag) DO NOTHING
+		`, cardID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to tag card %s: %w", cardID, err)
+		}
+	}
+
+	return nil
+}
+
+func cardTags(q queryer, cardID string) ([]string, error) {
+	rows, err := q.Query(`SELECT tag FROM card_tags WHERE card_id = ? ORDER BY tag`, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags for card %s: %w", cardID, err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// deckTags returns the tags of every card in a deck keyed by card ID.
+func (s *Store) deckTags(deckName string) (map[string][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT t.card_id, t.tag
+		FROM card_tags t
+		INNER JOIN cards c ON c.id = t.card_id
+		WHERE c.deck_id = ? AND c.deleted_at IS NULL
+		ORDER BY t.tag
+	`, deckName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags for deck %s: %w", deckName, err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var cardID, tag string
+		if err := rows.Scan(&cardID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[cardID] = append(tags[cardID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns every tag in the collection with the number of cards using it.
+func (s *Store) ListTags() ([]models.Tag, error) {
+	rows, err := s.db.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM card_tags t
+		INNER JOIN cards c ON c.id = t.card_id
+		WHERE c.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY tag
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// CardsByTag returns the cards tagged with tag or one of its children (a/b
+// is a child of a).
+func (s *Store) CardsByTag(tag string) ([]models.Flashcard, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.SearchCards(`tag:"`+tag+`"`, SearchOptions{})
+}
+
+// RenameTag renames a tag and its children (renaming a to b also renames
+// a/c to b/c) on every card, rewriting #hashtags in card content. It returns
+// the number of cards changed.
+func (s *Store) RenameTag(oldTag, newTag string) (int, error) {
+	oldTag, err := normalizeTag(oldTag)
+	if err != nil {
+		return 0, err
+	}
+	newTag, err = normalizeTag(newTag)
+	if err != nil {
+		return 0, err
+	}
+	if isChildTag(newTag, oldTag) {
+		return 0, fmt.Errorf("cannot rename tag %s to its child %s", oldTag, newTag)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tags, err := tagFamily(tx, oldTag)
+	if err != nil {
+		return 0, err
+	}
+	// A renamed tag can land on the old name of another one in the family,
+	// as when a/a/b is renamed to a/b by renaming a/a to a. Renaming tags
+	// that move towards their targets first keeps a tag from being renamed
+	// twice.
+	sort.Slice(tags, func(i, j int) bool {
+		if len(newTag) < len(oldTag) {
+			return len(tags[i]) < len(tags[j])
+		}
+		return len(tags[i]) > len(tags[j])
+	})
+
+	changed := make(map[string]bool)
+	for _, tag := range tags {
+		ids, err := renameTag(tx, tag, newTag+tag[len(oldTag):])
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			changed[id] = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tag rename: %w", err)
+	}
+	return len(changed), nil
+}
+
+// renameTag renames one tag on every card and returns the IDs of the cards
+// that had it.
+func renameTag(tx *sql.Tx, oldTag, newTag string) ([]string, error) {
+	cards, err := taggedCards(tx, oldTag)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(cards))
+	for id, card := range cards {
+		ids = append(ids, id)
+		updated := md.RenameTag([]byte(card.Content), oldTag, newTag)
+		if string(updated) == card.Content {
+			continue
+		}
+		if err := snapshotCard(tx, id, card.Title, string(updated)); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec(`
+			UPDATE cards SET content = ?, updated_at = ? WHERE id = ?
+		`, string(updated), time.Now().Unix(), id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update card %s: %w", id, err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE OR IGNORE card_tags SET tag = ? WHERE tag = ?`, newTag, oldTag); err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+	if !strings.EqualFold(oldTag, newTag) {
+		// Cards that already had newTag keep their row; drop the old one.
+		if _, err := tx.Exec(`DELETE FROM card_tags WHERE tag = ?`, oldTag); err != nil {
+			return nil, fmt.Errorf("failed to rename tag: %w", err)
+		}
+	}
+	return ids, nil
+}
+
+// tagFamily returns tag, as it's spelled in the collection, and every tag
+// that is a child of it.
+func tagFamily(tx *sql.Tx, tag string) ([]string, error) {
+	rows, err := tx.Query(`SELECT DISTINCT tag FROM card_tags`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if strings.EqualFold(t, tag) || isChildTag(t, tag) {
+			tags = append(tags, t)
+		}
+	}
+	return tags, rows.Err()
+}
+
+// isChildTag reports whether tag is below parent, like a/b and a/b/c are
+// below a.
+func isChildTag(tag, parent string) bool {
+	return len(tag) > len(parent)+1 && tag[len(parent)] == '/' && strings.EqualFold(tag[:len(parent)], parent)
+}
+
+// AddTags tags cards without touching their content. It returns the number
+// of cards that gained at least one tag.
+func (s *Store) AddTags(cardIDs []string, tags []string) (int, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, err := addTags(tx, cardIDs, tags)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tags: %w", err)
+	}
+	return n, nil
+}
+
+func addTags(tx *sql.Tx, cardIDs []string, tags []string) (int, error) {
+	count := 0
+	for _, id := range cardIDs {
+		changed := false
+		for _, tag := range tags {
+			result, err := tx.Exec(`
+				INSERT INTO card_tags (card_id, tag, manual)
+				SELECT id, ?, 1 FROM cards WHERE id = ? AND deleted_at IS NULL
+				ON CONFLICT(card_id, tag) DO NOTHING
+			`, tag, id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to tag card %s: %w", id, err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				changed = true
+			}
+		}
+		if changed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// RemoveTags removes tags from cards, deleting matching #hashtags from their
+// content so the tags don't come back on the next edit. It returns the
+// number of cards that lost at least one tag.
+func (s *Store) RemoveTags(cardIDs []string, tags []string) (int, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	n, err := removeTags(tx, cardIDs, tags)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tags: %w", err)
+	}
+	return n, nil
+}
+
+func removeTags(tx *sql.Tx, cardIDs []string, tags []string) (int, error) {
+	count := 0
+	for _, id := range cardIDs {
+		var title, content string
+		err := tx.QueryRow(`SELECT title, content FROM cards WHERE id = ? AND deleted_at IS NULL`, id).Scan(&title, &content)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to load card %s: %w", id, err)
+		}
+
+		updated := []byte(content)
+		for _, tag := range tags {
+			updated = md.RemoveTag(updated, tag)
+		}
+		if string(updated) != content {
+			if err := snapshotCard(tx, id, title, string(updated)); err != nil {
+				return 0, err
+			}
+			_, err := tx.Exec(`
+				UPDATE cards SET content = ?, updated_at = ? WHERE id = ?
+			`, string(updated), time.Now().Unix(), id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to update card %s: %w", id, err)
+			}
+		}
+
+		changed := false
+		for _, tag := range tags {
+			result, err := tx.Exec(`DELETE FROM card_tags WHERE card_id = ? AND tag = ?`, id, tag)
+			if err != nil {
+				return 0, fmt.Errorf("failed to untag card %s: %w", id, err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				changed = true
+			}
+		}
+		if changed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// taggedCards returns every card tagged with tag, keyed by ID.
+func taggedCards(tx *sql.Tx, tag string) (map[string]models.Flashcard, error) {
+	rows, err := tx.Query(`
+		SELECT c.id, c.title, c.content
+		FROM cards c
+		INNER JOIN card_tags t ON t.card_id = c.id
+		WHERE t.tag = ?
+	`, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cards tagged %s: %w", tag, err)
+	}
+	defer rows.Close()
+
+	cards := make(map[string]models.Flashcard)
+	for rows.Next() {
+		var card models.Flashcard
+		if err := rows.Scan(&card.ID, &card.Title, &card.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		cards[card.ID] = card
+	}
+	return cards, rows.Err()
+}
+
+func normalizeTag(tag string) (string, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	if tag == "" {
+		return "", fmt.Errorf("tag cannot be empty")
+	}
+	if strings.ContainsAny(tag, " \t\n\"") {
+		return "", fmt.Errorf("invalid tag %q: tags cannot contain spaces or quotes", tag)
+	}
+	return tag, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
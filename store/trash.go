@@ -0,0 +1,178 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dfirebaugh/mdsrs/models"
+)
+
+const (
+	TrashKindCard = "card"
+	TrashKindDeck = "deck"
+)
+
+// ListTrash returns trashed decks and the trashed cards of live decks, most
+// recently deleted first. Cards trashed along with their deck are counted on
+// the deck rather than listed. Everything trashed by one deletion shares a
+// trash batch, and is restored together.
+func (s *Store) ListTrash() ([]models.TrashItem, error) {
+	rows, err := s.db.Query(`
+		SELECT 'deck', d.name, d.name, d.name, d.deleted_at,
+			(SELECT COUNT(*) FROM cards c WHERE c.deck_id = d.name AND c.deleted_at IS NOT NULL AND c.trash_batch = d.trash_batch)
+		FROM decks d
+		WHERE d.deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'card', c.id, c.deck_id, c.title, c.deleted_at, 1
+		FROM cards c
+		INNER JOIN decks d ON d.name = c.deck_id
+		WHERE c.deleted_at IS NOT NULL AND d.deleted_at IS NULL
+		ORDER BY 5 DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		var title sql.NullString
+		err := rows.Scan(&item.Kind, &item.ID, &item.DeckID, &title, &item.DeletedAt, &item.CardCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		item.Title = title.String
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// RestoreCard takes a card out of the trash with its SRS data intact. If its
+// deck is trashed too, the deck is restored without its other cards.
+func (s *Store) RestoreCard(cardID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deckName string
+	var batch sql.NullString
+	err = tx.QueryRow(`
+		SELECT deck_id, trash_batch FROM cards WHERE id = ? AND deleted_at IS NOT NULL
+	`, cardID).Scan(&deckName, &batch)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("card not found in trash: %s", cardID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find card: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE cards SET deleted_at = NULL, trash_batch = NULL WHERE id = ?`, cardID); err != nil {
+		return fmt.Errorf("failed to restore card: %w", err)
+	}
+	// Cards generated from this one were trashed along with it.
+	_, err = tx.Exec(`
+		UPDATE cards SET deleted_at = NULL, trash_batch = NULL
+		WHERE source_id = ? AND deleted_at IS NOT NULL AND trash_batch = ?
+	`, cardID, batch)
+	if err != nil {
+		return fmt.Errorf("failed to restore generated cards: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE decks SET deleted_at = NULL, trash_batch = NULL WHERE name = ?`, deckName); err != nil {
+		return fmt.Errorf("failed to restore deck %s: %w", deckName, err)
+	}
+
+	return tx.Commit()
+}
+
+// RestoreDeck takes a deck out of the trash along with the cards that were
+// deleted with it.
+func (s *Store) RestoreDeck(deckName string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var batch sql.NullString
+	err = tx.QueryRow(`
+		SELECT trash_batch FROM decks WHERE name = ? AND deleted_at IS NOT NULL
+	`, deckName).Scan(&batch)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("deck not found in trash: %s", deckName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find deck: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE cards SET deleted_at = NULL, trash_batch = NULL
+		WHERE deck_id = ? AND deleted_at IS NOT NULL AND trash_batch = ?
+	`, deckName, batch)
+	if err != nil {
+		return fmt.Errorf("failed to restore cards for deck %s: %w", deckName, err)
+	}
+	if _, err := tx.Exec(`UPDATE decks SET deleted_at = NULL, trash_batch = NULL WHERE name = ?`, deckName); err != nil {
+		return fmt.Errorf("failed to restore deck %s: %w", deckName, err)
+	}
+
+	return tx.Commit()
+}
+
+// PurgeTrash permanently deletes decks and cards trashed at or before the
+// given unix time, along with their SRS data, tags, revisions and notes. It
+// returns the number of cards deleted.
+func (s *Store) PurgeTrash(before int64) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Cards go when they or their deck expire.
+	_, err = tx.Exec(`
+		CREATE TEMP TABLE purged_cards AS
+		SELECT c.id FROM cards c
+		LEFT JOIN decks d ON d.name = c.deck_id
+		WHERE c.deleted_at <= ? OR d.deleted_at <= ?
+	`, before, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect trashed cards: %w", err)
+	}
+
+	for _, table := range []string{"srs_data", "card_tags", "card_revisions", "review_log"} {
+		_, err := tx.Exec(`DELETE FROM ` + table + ` WHERE card_id IN (SELECT id FROM temp.purged_cards)`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM cards WHERE id IN (SELECT id FROM temp.purged_cards)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge cards: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(`DROP TABLE temp.purged_cards`); err != nil {
+		return 0, fmt.Errorf("failed to purge cards: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM decks WHERE deleted_at <= ?`, before); err != nil {
+		return 0, fmt.Errorf("failed to purge decks: %w", err)
+	}
+
+	// Notes go with the last of their cards.
+	_, err = tx.Exec(`
+		DELETE FROM notes WHERE id NOT IN (SELECT note_id FROM cards WHERE note_id IS NOT NULL)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge notes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return int(purged), nil
+}